@@ -5,26 +5,43 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	_ "github.com/sm8ta/webike_user_microservice_nikita/docs"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/bikeservice"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/cache"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/events"
+	grpcserver "github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/handler/grpc"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/handler/http"
 	handlers "github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/handler/http"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/logger"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/prometheus"
 	redis "github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/redis"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/tracing"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/webhook"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	redisClient "github.com/redis/go-redis/v9"
 
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/postgres"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/postgres/repository"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/config"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/services"
 
+	"github.com/XSAM/otelsql"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/lib/pq"
-	"github.com/pressly/goose"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 // @title User Microservice API
@@ -37,36 +54,100 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+
+// @securityDefinitions.basic BasicAuth
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Loading environment
 	cfg, err := config.New()
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 	// Set logger
-	loggerAdapter := logger.NewLoggerAdapter(cfg.App.Env)
+	loggerAdapter, err := logger.NewLoggerAdapter(cfg.App.Env, logger.RedactionRules{
+		Enabled:    cfg.Logging.Redact,
+		HashKey:    cfg.Logging.RedactHashKey,
+		MaskFields: cfg.Logging.RedactMaskFields,
+		HashFields: cfg.Logging.RedactHashFields,
+		DropFields: cfg.Logging.RedactDropFields,
+	})
+	if err != nil {
+		log.Fatalf("Error creating logger, set LOG_REDACT_HASH_KEY: %v", err)
+	}
 	loggerAdapter.Info("Starting the application", map[string]interface{}{
 		"app": cfg.App.Name,
 		"env": cfg.App.Env,
 	})
 
+	ctx, cancelApp := context.WithCancel(context.Background())
+	defer cancelApp()
+
+	// Tracing
+	shutdownTracing, err := tracing.NewTracerProvider(ctx, cfg.Tracing, cfg.App)
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %v", err)
+	}
+
 	// Set redis
 	redisConn := redisClient.NewClient(&redisClient.Options{
-		Addr:     cfg.Redis.Address,
-		Password: cfg.Redis.Password,
-		DB:       0,
+		Addr:         cfg.Redis.Address,
+		Password:     cfg.Redis.Password,
+		DB:           0,
+		DialTimeout:  cfg.Redis.Timeout,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
 	})
+	if err := redisotel.InstrumentTracing(redisConn); err != nil {
+		log.Fatalf("Failed to instrument Redis: %v", err)
+	}
+
+	// Observability
+	metrics := prometheus.NewPrometheusAdapter(cfg.App.Name)
+
+	// Cache, the service runs in degraded mode while Redis is unavailable
+	redisHealth := redis.NewHealthChecker(redisConn)
+	redisCache := cache.NewCircuitBreakerCache(
+		cache.NewInstrumentedCache(redis.NewRedisAdapter(redisConn), "redis", metrics),
+		redisHealth,
+		cache.CircuitBreakerSettings{
+			FailureThreshold: cfg.Redis.BreakerThreshold,
+			OpenTimeout:      cfg.Redis.BreakerOpenTimeout,
+			ProbeInterval:    cfg.Redis.BreakerProbeInterval,
+			ProbeTimeout:     cfg.Redis.Timeout,
+		},
+		loggerAdapter,
+		metrics,
+	)
+	if err := redisHealth.Check(ctx); err != nil {
+		loggerAdapter.Warn("Redis is unavailable, starting without cache", map[string]interface{}{
+			"error": err.Error(),
+		})
+		redisCache.Trip()
+	}
+	go redisCache.Run(ctx)
 
-	ctx := context.Background()
-	if _, err := redisConn.Ping(ctx).Result(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	// Two-tier cache: hot keys stay in memory, invalidations reach other replicas over pub/sub
+	var cacheAdapter ports.CachePort = redisCache
+	if cfg.Cache.L1Size > 0 {
+		tieredCache := cache.NewTieredCache(
+			cache.NewInstrumentedCache(cache.NewMemoryCache(cfg.Cache.L1Size), "memory", metrics),
+			redisCache,
+			cfg.Cache.L1TTL,
+			redis.NewInvalidator(redisConn, redisCache, cfg.Cache.InvalidationChannel, uuid.NewString()),
+			loggerAdapter,
+		)
+		go tieredCache.Run(ctx)
+		cacheAdapter = tieredCache
 	}
 
 	// Connect DB
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DB.Host, cfg.DB.Port, cfg.DB.User, cfg.DB.Password, cfg.DB.Name)
-	db, err := sql.Open("postgres", dsn)
-
+	db, err := openDB(cfg.DB, cfg.DB.Host, cfg.DB.Port)
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
@@ -76,39 +157,171 @@ func main() {
 		log.Fatal("Failed to ping database: ", err)
 	}
 
-	// Migrate DB
-	if err := goose.Up(db, "./internal/adapter/postgres/migrations"); err != nil {
-		log.Fatal("Failed to run migrations: ", err)
+	metrics.RegisterDBStats(db, cfg.DB.Name)
+
+	// Read replicas are checked in the background, reads use the primary until then
+	replicas := make([]postgres.Replica, 0, len(cfg.DB.Replicas))
+	for _, addr := range cfg.DB.Replicas {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			log.Fatal("Invalid database replica address: ", err)
+		}
+		replicaDB, err := openDB(cfg.DB, host, port)
+		if err != nil {
+			log.Fatal("Failed to connect to database replica: ", err)
+		}
+		defer replicaDB.Close()
+
+		metrics.RegisterDBStats(replicaDB, cfg.DB.Name+"@"+addr)
+		replicas = append(replicas, postgres.Replica{Name: addr, DB: replicaDB})
 	}
+	dbs := postgres.NewReplicaSet(db, replicas, loggerAdapter, metrics, postgres.ReplicaSettings{
+		CheckInterval: cfg.DB.ReplicaCheckInterval,
+		CheckTimeout:  cfg.DB.ReplicaCheckTimeout,
+		MaxLag:        cfg.DB.ReplicaMaxLag,
+	})
+	go dbs.Run(ctx)
 
-	// Cache
-	cacheAdapter := redis.NewRedisAdapter(redisConn)
+	// Schema changes are applied by the migrate command, a build ahead of the database does not start
+	migrator, err := postgres.NewMigrator(db)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+	if err := migrator.CheckVersion(ctx); err != nil {
+		log.Fatal("Failed to verify database schema: ", err)
+	}
 
 	// Validate
 	validate := validator.New()
 
-	// Observability
-	metrics := prometheus.NewPrometheusAdapter()
-
-	// User service client init
-	//transport := httptransport.New(cfg.BikeService.URL, "", []string{"http"})
-	//bikeClient := bike_client.New(transport, strfmt.Default)
+	// Bike service client
+	bikeClient := bikeservice.NewClient(bikeservice.ClientSettings{
+		BaseURL:            cfg.BikeService.URL,
+		BikesPath:          cfg.BikeService.BikesPath,
+		UserBikesPath:      cfg.BikeService.UserBikesPath,
+		ServiceToken:       cfg.BikeService.Token,
+		Timeout:            cfg.BikeService.Timeout,
+		MaxRetries:         cfg.BikeService.MaxRetries,
+		RetryBaseDelay:     cfg.BikeService.RetryBaseDelay,
+		BreakerThreshold:   cfg.BikeService.BreakerThreshold,
+		BreakerOpenTimeout: cfg.BikeService.BreakerOpenTimeout,
+	}, loggerAdapter, metrics)
 
 	// User
-	userRepo := repository.NewUserRepository(db)
+	var userRepo ports.UserRepository = repository.NewUserRepository(dbs, cfg.DB.QueryTimeout)
+	if cfg.DB.Driver == "pgx" {
+		userRepo = repository.NewPgxUserRepository(dbs, cfg.DB.QueryTimeout)
+	}
+	txManager := repository.NewTxManager(db, repository.TxSettings{
+		MaxRetries:     cfg.DB.TxMaxRetries,
+		RetryBaseDelay: cfg.DB.TxRetryBaseDelay,
+	})
 	tokenService := handlers.NewJWTTokenService(cfg.Token.Secret, cfg.Token.Duration, loggerAdapter)
-	authService := services.NewAuthService(userRepo, tokenService, loggerAdapter, cacheAdapter)
-	authHandler := handlers.NewAuthHandler(authService, loggerAdapter, metrics)
-	userService := services.NewUserService(userRepo, loggerAdapter, validate, cacheAdapter)
+	cacheSettings := services.CacheSettings{
+		NegativeTTL:      cfg.Cache.NegativeTTL,
+		EarlyRefreshBeta: cfg.Cache.EarlyRefreshBeta,
+		BikesTTL:         cfg.BikeService.CacheTTL,
+	}
+	auditService := services.NewAuditService(repository.NewAuditRepository(db, cfg.DB.QueryTimeout), loggerAdapter)
+	auditHandler := handlers.NewAuditHandler(auditService, loggerAdapter)
+	revocationStore := redis.NewRevocationStore(redisConn, redisCache, cfg.Token.RevocationFailOpen)
+	clientRepo := repository.NewServiceClientRepository(db, cfg.DB.QueryTimeout)
+	authService := services.NewAuthService(userRepo, clientRepo, tokenService, revocationStore, loggerAdapter, metrics, auditService, cacheAdapter, cacheSettings, services.AuthSettings{
+		RevocationFailOpen: cfg.Token.RevocationFailOpen,
+		ClientCacheTTL:     cfg.Token.ClientCacheTTL,
+	})
+	authHandler := handlers.NewAuthHandler(authService, loggerAdapter)
+	serviceClientService := services.NewServiceClientService(clientRepo, tokenService, loggerAdapter, auditService, cfg.Token.ServiceDuration)
+	serviceClientHandler := handlers.NewServiceClientHandler(serviceClientService, loggerAdapter)
+	// Account deletion runs as a saga across the bike service
+	deletionService := services.NewUserDeletionService(
+		repository.NewUserDeletionRepository(db, cfg.DB.QueryTimeout),
+		userRepo,
+		bikeClient,
+		loggerAdapter,
+		auditService,
+		cacheAdapter,
+		cacheSettings,
+		services.DeletionSettings{
+			MaxAttempts:    cfg.Deletion.MaxAttempts,
+			BaseBackoff:    cfg.Deletion.BaseBackoff,
+			MaxBackoff:     cfg.Deletion.MaxBackoff,
+			PollInterval:   cfg.Deletion.PollInterval,
+			BatchSize:      cfg.Deletion.BatchSize,
+			Lease:          cfg.Deletion.Lease,
+			AdvanceTimeout: cfg.Deletion.AdvanceTimeout,
+		},
+	)
+	deletionHandler := handlers.NewDeletionHandler(deletionService, loggerAdapter)
+	go deletionService.Run(ctx)
+	userService := services.NewUserService(userRepo, deletionService, txManager, loggerAdapter, metrics, auditService, validate, cacheAdapter, cacheSettings, bikeClient)
+
+	userHandler := handlers.NewUserHandler(userService, loggerAdapter, tokenService)
+
+	// Events written by the repositories are relayed from the outbox
+	publisher, closePublisher, err := newEventPublisher(ctx, cfg.Events, loggerAdapter, metrics)
+	if err != nil {
+		log.Fatal("Failed to create event publisher: ", err)
+	}
+	defer closePublisher()
 
-	userHandler := handlers.NewUserHandler(userService, loggerAdapter, tokenService, metrics)
+	// Partner webhooks get the same events as the broker
+	webhookService := services.NewWebhookService(
+		repository.NewWebhookRepository(db, cfg.DB.QueryTimeout),
+		webhook.NewSender(cfg.Webhooks.Timeout, cfg.Events.Source),
+		loggerAdapter,
+		metrics,
+		auditService,
+		services.WebhookSettings{
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			BaseBackoff:  cfg.Webhooks.BaseBackoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+			PollInterval: cfg.Webhooks.PollInterval,
+			BatchSize:    cfg.Webhooks.BatchSize,
+			Concurrency:  cfg.Webhooks.Concurrency,
+			Lease:        cfg.Webhooks.Lease,
+		},
+	)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, loggerAdapter)
+	go webhookService.Run(ctx)
+
+	outboxRelay := postgres.NewOutboxRelay(db, events.NewFanoutPublisher(publisher, webhookService), loggerAdapter, metrics, postgres.OutboxSettings{
+		PollInterval:    cfg.Outbox.PollInterval,
+		BatchSize:       cfg.Outbox.BatchSize,
+		Retention:       cfg.Outbox.Retention,
+		CleanupInterval: cfg.Outbox.CleanupInterval,
+		MaxAttempts:     cfg.Outbox.MaxAttempts,
+		BaseBackoff:     cfg.Outbox.BaseBackoff,
+		MaxBackoff:      cfg.Outbox.MaxBackoff,
+	})
+	go outboxRelay.Run(ctx)
+
+	// Health
+	healthHandler := handlers.NewHealthHandler(
+		[]ports.HealthChecker{
+			postgres.NewHealthChecker(db),
+			redisCache,
+			revocationStore,
+			bikeservice.NewHealthChecker(cfg.BikeService.URL, cfg.BikeService.HealthPath),
+		},
+		cfg.Health.CheckTimeout,
+		loggerAdapter,
+	)
 
 	// Init router
 	router, err := http.NewRouter(
 		cfg.HTTP,
-		tokenService,
+		cfg.Introspection,
+		authService,
 		userHandler,
 		authHandler,
+		healthHandler,
+		auditHandler,
+		serviceClientHandler,
+		webhookHandler,
+		deletionHandler,
+		serviceClientService,
+		metrics,
 	)
 	if err != nil {
 		log.Fatal("Error initializing router:", err)
@@ -125,6 +338,20 @@ func main() {
 		}
 	}()
 
+	// gRPC for internal services
+	grpcServer := grpcserver.NewServer(cfg.GRPC, userService, authService, tokenService, loggerAdapter, metrics)
+
+	go func() {
+		listenAddr := fmt.Sprintf("%s:%s", cfg.HTTP.URL, cfg.GRPC.Port)
+		loggerAdapter.Info("Starting the gRPC server", map[string]interface{}{
+			"addr": listenAddr,
+		})
+
+		if err := grpcServer.Serve(listenAddr); err != nil {
+			log.Fatal("Error starting the gRPC server:", err)
+		}
+	}()
+
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
@@ -133,6 +360,124 @@ func main() {
 
 	<-stop
 
-	loggerAdapter.Info("Shut", nil)
+	// Readiness goes down first so the load balancer stops sending traffic
+	healthHandler.SetShuttingDown()
+	grpcServer.SetShuttingDown()
+	loggerAdapter.Info("Shutting down, draining traffic", map[string]interface{}{
+		"drain_delay": cfg.HTTP.DrainDelay.String(),
+	})
+	time.Sleep(cfg.HTTP.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := router.Shutdown(shutdownCtx); err != nil {
+		loggerAdapter.Error("HTTP server shutdown failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	grpcServer.Shutdown(shutdownCtx)
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		loggerAdapter.Error("Failed to flush traces", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	cancelApp()
 	loggerAdapter.Info("Application stopped", nil)
 }
+
+// newEventPublisher picks the transport of user events,
+// the returned func releases its connections
+func newEventPublisher(ctx context.Context, cfg *config.Events, logger ports.LoggerPort, metrics ports.MetricsPort) (ports.EventPublisher, func(), error) {
+	switch cfg.Transport {
+	case "nats":
+		publisher, err := events.NewNATSPublisher(ctx, events.NATSSettings{
+			URL:            cfg.NATSURL,
+			Stream:         cfg.NATSStream,
+			SubjectPrefix:  cfg.NATSSubjectPrefix,
+			Source:         cfg.Source,
+			PublishTimeout: cfg.PublishTimeout,
+		}, logger, metrics)
+		if err != nil {
+			return nil, nil, err
+		}
+		return publisher, func() { publisher.Close() }, nil
+	case "kafka":
+		if len(cfg.KafkaBrokers) == 0 {
+			return nil, nil, fmt.Errorf("KAFKA_BROKERS is required for the kafka transport")
+		}
+		publisher := events.NewKafkaPublisher(events.KafkaSettings{
+			Brokers:        cfg.KafkaBrokers,
+			Topic:          cfg.KafkaTopic,
+			Source:         cfg.Source,
+			PublishTimeout: cfg.PublishTimeout,
+		}, metrics)
+		return publisher, func() { publisher.Close() }, nil
+	case "log":
+		return events.NewLogPublisher(logger), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown events transport %q", cfg.Transport)
+	}
+}
+
+// openDB connects to one Postgres host with the credentials of cfg
+func openDB(cfg *config.DB, host, port string) (*sql.DB, error) {
+	dsn := postgresDSN(cfg, host, port)
+	attributes := otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL)
+
+	var db *sql.DB
+	switch cfg.Driver {
+	case "pq":
+		var err error
+		if db, err = otelsql.Open("postgres", dsn, attributes); err != nil {
+			return nil, err
+		}
+	case "pgx":
+		connConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		db = otelsql.OpenDB(stdlib.GetConnector(*connConfig), attributes)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// postgresDSN quotes every value, passwords may contain spaces and quotes
+func postgresDSN(cfg *config.DB, host, port string) string {
+	params := [][2]string{
+		{"host", host},
+		{"port", port},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.Name},
+		{"sslmode", cfg.SSLMode},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	}
+	if cfg.StatementTimeout > 0 {
+		// Unknown keys are sent to the server as session parameters
+		params = append(params, [2]string{"statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param[1] == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s='%s'", param[0], dsnEscaper.Replace(param[1])))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/postgres"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/postgres/migrations"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/config"
+
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down           roll back the latest migration
+  redo           roll back the latest migration and apply it again
+  status         list migrations and when they were applied
+  create <name>  add an empty SQL migration to ` + migrations.Dir
+
+// runMigrate runs the migrate subcommand, args follow "migrate"
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	// Needs the source tree only, not a database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		return goose.Create(nil, migrations.Dir, args[1], "sql")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	// Migrations such as concurrent index builds may outlast the statement timeout of the service
+	dbConfig := *cfg.DB
+	dbConfig.StatementTimeout = 0
+
+	db, err := openDB(&dbConfig, dbConfig.Host, dbConfig.Port)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := postgres.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		printMigrationResults(results...)
+		if err == nil && len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		result, err := migrator.Down(ctx)
+		if result != nil {
+			printMigrationResults(result)
+		}
+		return err
+	case "redo":
+		results, err := migrator.Redo(ctx)
+		printMigrationResults(results...)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatuses(statuses)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+func printMigrationResults(results ...*goose.MigrationResult) {
+	for _, result := range results {
+		fmt.Println(result)
+	}
+}
+
+func printMigrationStatuses(statuses []*goose.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+	}
+}
@@ -0,0 +1,242 @@
+package bikeservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/breaker"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+type ClientSettings struct {
+	BaseURL   string
+	BikesPath string
+	// Deletes the bikes of a user, {id} is replaced with the user ID
+	UserBikesPath string
+	// Bearer token for calls made on behalf of this service
+	ServiceToken string
+	// Per attempt
+	Timeout        time.Duration
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+
+	BreakerThreshold   int
+	BreakerOpenTimeout time.Duration
+}
+
+// Client calls the bike service over HTTP.
+// Failed attempts are retried with exponential backoff and full jitter,
+// and after repeated failures the breaker fails calls fast until the service is back
+type Client struct {
+	client   *http.Client
+	settings ClientSettings
+	breaker  *breaker.Breaker
+	logger   ports.LoggerPort
+}
+
+type bikesResponse struct {
+	Data []domain.Bike `json:"data"`
+}
+
+// Status codes that say nothing about the request itself
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+func NewClient(settings ClientSettings, logger ports.LoggerPort, metrics ports.MetricsPort) *Client {
+	c := &Client{
+		client: &http.Client{
+			Timeout:   settings.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		settings: settings,
+		logger:   logger,
+	}
+
+	c.breaker = breaker.New(breaker.Settings{
+		Name:             "bike_service",
+		FailureThreshold: settings.BreakerThreshold,
+		OpenTimeout:      settings.BreakerOpenTimeout,
+		OnStateChange: func(name string, from, to breaker.State) {
+			metrics.SetGauge(ports.MetricCircuitBreakerState, float64(to), map[string]string{
+				"name": name,
+			})
+			logger.Warn("Bike service circuit breaker state changed", map[string]interface{}{
+				"name": name,
+				"from": from.String(),
+				"to":   to.String(),
+			})
+		},
+	})
+	metrics.SetGauge(ports.MetricCircuitBreakerState, float64(breaker.Closed), map[string]string{
+		"name": "bike_service",
+	})
+
+	return c
+}
+
+func (c *Client) GetUserBikes(ctx context.Context, token string) ([]domain.Bike, error) {
+	var bikes []domain.Bike
+	err := c.call(ctx, func() (bool, error) {
+		var (
+			retryable bool
+			err       error
+		)
+		bikes, retryable, err = c.getBikes(ctx, token)
+		return retryable, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bikes, nil
+}
+
+func (c *Client) DeleteUserBikes(ctx context.Context, userID uuid.UUID) error {
+	return c.call(ctx, func() (bool, error) {
+		return c.deleteBikes(ctx, userID)
+	})
+}
+
+// call runs attempt through the breaker, repeating it while it fails with a retryable error
+func (c *Client) call(ctx context.Context, attempt func() (bool, error)) error {
+	if err := c.breaker.Allow(); err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrBikeServiceUnavailable, err)
+	}
+
+	var err error
+	for i := 0; ; i++ {
+		var retryable bool
+		retryable, err = attempt()
+		if err == nil || !retryable || i >= c.settings.MaxRetries {
+			break
+		}
+
+		if waitErr := sleep(ctx, c.backoff(i)); waitErr != nil {
+			err = waitErr
+			break
+		}
+	}
+
+	switch {
+	case err == nil:
+		c.breaker.Success()
+		return nil
+	case errors.Is(err, context.Canceled):
+		c.breaker.Release()
+		return err
+	case errors.Is(err, domain.ErrBikeServiceUnavailable):
+		c.breaker.Failure()
+		return err
+	default:
+		// The service answered, it just did not like the request
+		c.breaker.Success()
+		return err
+	}
+}
+
+// getBikes makes one attempt and tells whether it is worth repeating
+func (c *Client) getBikes(ctx context.Context, token string) ([]domain.Bike, bool, error) {
+	url := strings.TrimRight(c.settings.BaseURL, "/") + c.settings.BikesPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		return nil, true, fmt.Errorf("%w: %w", domain.ErrBikeServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if retryable, err := checkStatus(resp, http.StatusOK); err != nil {
+		return nil, retryable, err
+	}
+
+	var body bikesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, false, fmt.Errorf("%w: invalid response: %w", domain.ErrBikeServiceUnavailable, err)
+	}
+	if body.Data == nil {
+		body.Data = []domain.Bike{}
+	}
+	return body.Data, false, nil
+}
+
+// deleteBikes makes one attempt and tells whether it is worth repeating
+func (c *Client) deleteBikes(ctx context.Context, userID uuid.UUID) (bool, error) {
+	path := strings.ReplaceAll(c.settings.UserBikesPath, "{id}", userID.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, strings.TrimRight(c.settings.BaseURL, "/")+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.settings.ServiceToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, fmt.Errorf("%w: %w", domain.ErrBikeServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	// Not found is rejected too: a wrong path or base URL must not pass for a user without bikes
+	return checkStatus(resp, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+}
+
+// checkStatus turns an unexpected status into an error and tells whether it is worth repeating
+func checkStatus(resp *http.Response, expected ...int) (bool, error) {
+	if slices.Contains(expected, resp.StatusCode) {
+		return false, nil
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if retryableStatus[resp.StatusCode] || resp.StatusCode >= http.StatusInternalServerError {
+		return retryableStatus[resp.StatusCode], fmt.Errorf("%w: status %d", domain.ErrBikeServiceUnavailable, resp.StatusCode)
+	}
+	return false, fmt.Errorf("bike service rejected the request: status %d", resp.StatusCode)
+}
+
+// Full jitter: a random delay up to base * 2^attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.settings.RetryBaseDelay << attempt
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+var _ ports.BikeServicePort = (*Client)(nil)
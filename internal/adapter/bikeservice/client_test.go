@@ -0,0 +1,136 @@
+package bikeservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/breaker"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// newTestServer answers with statuses in turn, repeating the last one, and counts requests
+func newTestServer(t *testing.T, body string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		if status == http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = w.Write([]byte(body))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func newTestClient(baseURL string, maxRetries, breakerThreshold int) *Client {
+	return NewClient(ClientSettings{
+		BaseURL:            baseURL,
+		BikesPath:          "/bikes",
+		UserBikesPath:      "/internal/users/{id}/bikes",
+		ServiceToken:       "service-token",
+		Timeout:            time.Second,
+		MaxRetries:         maxRetries,
+		RetryBaseDelay:     time.Millisecond,
+		BreakerThreshold:   breakerThreshold,
+		BreakerOpenTimeout: time.Minute,
+	}, testutil.NopLogger{}, testutil.NopMetrics{})
+}
+
+func TestClient_RetriesUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{name: "service unavailable", status: http.StatusServiceUnavailable},
+		{name: "too many requests", status: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newTestServer(t, `{"data":[{"id":"b1"}]}`, tt.status, tt.status, http.StatusOK)
+			client := newTestClient(srv.URL, 3, 5)
+
+			bikes, err := client.GetUserBikes(context.Background(), "user-token")
+			if err != nil {
+				t.Fatalf("GetUserBikes: %v", err)
+			}
+			if len(bikes) != 1 {
+				t.Errorf("got %d bikes, want 1", len(bikes))
+			}
+			if got := requests.Load(); got != 3 {
+				t.Errorf("server got %d requests, want 3", got)
+			}
+		})
+	}
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	srv, requests := newTestServer(t, "", http.StatusServiceUnavailable)
+	client := newTestClient(srv.URL, 2, 5)
+
+	err := client.DeleteUserBikes(context.Background(), uuid.New())
+	if !errors.Is(err, domain.ErrBikeServiceUnavailable) {
+		t.Fatalf("err = %v, want ErrBikeServiceUnavailable", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("server got %d requests, want 3", got)
+	}
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			srv, requests := newTestServer(t, "", status)
+			client := newTestClient(srv.URL, 3, 5)
+
+			err := client.DeleteUserBikes(context.Background(), uuid.New())
+			if err == nil {
+				t.Fatal("DeleteUserBikes succeeded")
+			}
+			if errors.Is(err, domain.ErrBikeServiceUnavailable) {
+				t.Errorf("err = %v, want a rejection the saga does not retry", err)
+			}
+			if got := requests.Load(); got != 1 {
+				t.Errorf("server got %d requests, want 1", got)
+			}
+		})
+	}
+}
+
+func TestClient_BreakerOpensAndFailsFast(t *testing.T) {
+	const threshold = 3
+
+	srv, requests := newTestServer(t, "", http.StatusServiceUnavailable)
+	client := newTestClient(srv.URL, 0, threshold)
+
+	for i := 0; i < threshold; i++ {
+		if err := client.DeleteUserBikes(context.Background(), uuid.New()); !errors.Is(err, domain.ErrBikeServiceUnavailable) {
+			t.Fatalf("call %d: err = %v, want ErrBikeServiceUnavailable", i+1, err)
+		}
+	}
+
+	err := client.DeleteUserBikes(context.Background(), uuid.New())
+	if !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("err = %v, want the breaker open", err)
+	}
+	if !errors.Is(err, domain.ErrBikeServiceUnavailable) {
+		t.Errorf("err = %v, want it to wrap ErrBikeServiceUnavailable", err)
+	}
+	if got := requests.Load(); got != threshold {
+		t.Errorf("server got %d requests, want %d", got, threshold)
+	}
+}
@@ -0,0 +1,52 @@
+package bikeservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// Bike service is reported in readiness, but it is not critical:
+// user endpoints keep working without it
+type HealthChecker struct {
+	client *http.Client
+	url    string
+}
+
+func NewHealthChecker(baseURL, healthPath string) ports.HealthChecker {
+	return &HealthChecker{
+		client: &http.Client{},
+		url:    strings.TrimRight(baseURL, "/") + healthPath,
+	}
+}
+
+func (h *HealthChecker) Name() string {
+	return "bike_service"
+}
+
+func (h *HealthChecker) Critical() bool {
+	return false
+}
+
+func (h *HealthChecker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+var _ ports.HealthChecker = (*HealthChecker)(nil)
@@ -0,0 +1,176 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+type Settings struct {
+	Name string
+	// Consecutive failures before the breaker opens
+	FailureThreshold int
+	// How long the breaker stays open before letting a trial call through
+	OpenTimeout   time.Duration
+	OnStateChange func(name string, from, to State)
+}
+
+// Breaker is a consecutive-failures circuit breaker.
+// Closed -> Open after FailureThreshold failures, Open -> HalfOpen after OpenTimeout,
+// HalfOpen lets one call through and closes on success or opens again on failure
+type Breaker struct {
+	mu       sync.Mutex
+	settings Settings
+	state    State
+	failures int
+	openedAt time.Time
+	trialing bool
+}
+
+func New(settings Settings) *Breaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+
+	return &Breaker{
+		settings: settings,
+		state:    Closed,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.settings.Name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.currentState(time.Now())
+}
+
+// Allow returns ErrOpen when the call must be skipped
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState(time.Now()) {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.trialing {
+			return ErrOpen
+		}
+		b.trialing = true
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trialing = false
+	b.setState(Closed)
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+	state := b.currentState(time.Now())
+	if state == HalfOpen {
+		b.open()
+		return
+	}
+
+	b.failures++
+	if state == Closed && b.failures >= b.settings.FailureThreshold {
+		b.open()
+	}
+}
+
+// Release frees the half-open trial slot when the call outcome says nothing
+// about the dependency, e.g. the caller cancelled it
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+}
+
+// Trip opens the breaker immediately, e.g. when the dependency is down at startup
+func (b *Breaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.open()
+}
+
+// Execute runs fn through the breaker
+func (b *Breaker) Execute(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		b.Failure()
+		return err
+	}
+
+	b.Success()
+	return nil
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(Open)
+}
+
+// Open state turns into HalfOpen lazily once OpenTimeout has passed
+func (b *Breaker) currentState(now time.Time) State {
+	if b.state == Open && now.Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(HalfOpen)
+	}
+	return b.state
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	if b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(b.settings.Name, from, state)
+	}
+}
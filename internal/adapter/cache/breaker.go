@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/breaker"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// CircuitBreakerCache skips the wrapped cache after repeated failures
+// and probes it in the background until it answers again
+type CircuitBreakerCache struct {
+	next          ports.CachePort
+	probe         ports.HealthChecker
+	breaker       *breaker.Breaker
+	probeInterval time.Duration
+	probeTimeout  time.Duration
+	logger        ports.LoggerPort
+}
+
+type CircuitBreakerSettings struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	ProbeInterval    time.Duration
+	ProbeTimeout     time.Duration
+}
+
+func NewCircuitBreakerCache(
+	next ports.CachePort,
+	probe ports.HealthChecker,
+	settings CircuitBreakerSettings,
+	logger ports.LoggerPort,
+	metrics ports.MetricsPort,
+) *CircuitBreakerCache {
+	c := &CircuitBreakerCache{
+		next:          next,
+		probe:         probe,
+		probeInterval: settings.ProbeInterval,
+		probeTimeout:  settings.ProbeTimeout,
+		logger:        logger,
+	}
+
+	c.breaker = breaker.New(breaker.Settings{
+		Name:             probe.Name(),
+		FailureThreshold: settings.FailureThreshold,
+		OpenTimeout:      settings.OpenTimeout,
+		OnStateChange: func(name string, from, to breaker.State) {
+			metrics.SetGauge(ports.MetricCircuitBreakerState, float64(to), map[string]string{
+				"name": name,
+			})
+			logger.Warn("Cache circuit breaker state changed", map[string]interface{}{
+				"name": name,
+				"from": from.String(),
+				"to":   to.String(),
+			})
+		},
+	})
+	metrics.SetGauge(ports.MetricCircuitBreakerState, float64(breaker.Closed), map[string]string{
+		"name": probe.Name(),
+	})
+
+	return c
+}
+
+func (c *CircuitBreakerCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	value, err := c.next.Get(ctx, key)
+	c.record(err)
+	return value, err
+}
+
+func (c *CircuitBreakerCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return c.execute(func() error {
+		return c.next.Set(ctx, key, value, ttl, tags...)
+	})
+}
+
+func (c *CircuitBreakerCache) Delete(ctx context.Context, key string) error {
+	return c.execute(func() error {
+		return c.next.Delete(ctx, key)
+	})
+}
+
+func (c *CircuitBreakerCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	values, err := c.next.MGet(ctx, keys...)
+	c.record(err)
+	return values, err
+}
+
+func (c *CircuitBreakerCache) MSet(ctx context.Context, items ...ports.CacheItem) error {
+	return c.execute(func() error {
+		return c.next.MSet(ctx, items...)
+	})
+}
+
+func (c *CircuitBreakerCache) DeleteMany(ctx context.Context, keys ...string) error {
+	return c.execute(func() error {
+		return c.next.DeleteMany(ctx, keys...)
+	})
+}
+
+func (c *CircuitBreakerCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	return c.execute(func() error {
+		return c.next.InvalidateTags(ctx, tags...)
+	})
+}
+
+func (c *CircuitBreakerCache) TagKeys(ctx context.Context, tags ...string) ([]string, error) {
+	resolver, ok := c.next.(ports.CacheTagResolver)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	keys, err := resolver.TagKeys(ctx, tags...)
+	c.record(err)
+	return keys, err
+}
+
+// Execute runs fn through the breaker, for Redis calls made outside the cache
+// that should fail fast with it
+func (c *CircuitBreakerCache) Execute(fn func() error) error {
+	return c.execute(fn)
+}
+
+// Trip opens the breaker right away, used when the cache is down at startup
+func (c *CircuitBreakerCache) Trip() {
+	c.breaker.Trip()
+}
+
+func (c *CircuitBreakerCache) State() breaker.State {
+	return c.breaker.State()
+}
+
+// Run probes the cache while the breaker is open and closes it on success.
+// Blocks until ctx is done
+func (c *CircuitBreakerCache) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.breaker.State() == breaker.Closed {
+				continue
+			}
+
+			probeCtx, cancel := context.WithTimeout(ctx, c.probeTimeout)
+			err := c.probe.Check(probeCtx)
+			cancel()
+
+			if err != nil {
+				c.logger.Debug("Cache is still unavailable", map[string]interface{}{
+					"name":  c.probe.Name(),
+					"error": err.Error(),
+				})
+				continue
+			}
+			c.breaker.Success()
+		}
+	}
+}
+
+// Health checker, reports the dependency together with the breaker state
+
+func (c *CircuitBreakerCache) Name() string {
+	return c.probe.Name()
+}
+
+func (c *CircuitBreakerCache) Critical() bool {
+	return c.probe.Critical()
+}
+
+func (c *CircuitBreakerCache) Check(ctx context.Context) error {
+	return c.probe.Check(ctx)
+}
+
+func (c *CircuitBreakerCache) HealthDetails() map[string]string {
+	return map[string]string{
+		"circuit_breaker": c.breaker.State().String(),
+	}
+}
+
+func (c *CircuitBreakerCache) execute(fn func() error) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	c.record(err)
+	return err
+}
+
+// Cache misses and cancelled requests say nothing about the cache health
+func (c *CircuitBreakerCache) record(err error) {
+	switch {
+	case err == nil, errors.Is(err, ports.ErrCacheMiss):
+		c.breaker.Success()
+	case errors.Is(err, context.Canceled):
+		c.breaker.Release()
+	default:
+		c.breaker.Failure()
+	}
+}
+
+var (
+	_ ports.CachePort        = (*CircuitBreakerCache)(nil)
+	_ ports.CacheTagResolver = (*CircuitBreakerCache)(nil)
+	_ ports.HealthChecker    = (*CircuitBreakerCache)(nil)
+	_ ports.HealthDetailer   = (*CircuitBreakerCache)(nil)
+)
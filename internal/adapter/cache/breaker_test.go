@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/breaker"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+)
+
+var errCacheDown = errors.New("cache is down")
+
+// flakyCache fails every call with err while it is set, and counts the calls that reached it
+type flakyCache struct {
+	testutil.NopCache
+
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (c *flakyCache) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *flakyCache) result() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return c.err
+}
+
+func (c *flakyCache) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func (c *flakyCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := c.result(); err != nil {
+		return nil, err
+	}
+	return nil, ports.ErrCacheMiss
+}
+
+func (c *flakyCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return c.result()
+}
+
+func (c *flakyCache) DeleteMany(ctx context.Context, keys ...string) error {
+	return c.result()
+}
+
+type fakeProbe struct {
+	err error
+}
+
+func (p *fakeProbe) Name() string                    { return "fake" }
+func (p *fakeProbe) Critical() bool                  { return false }
+func (p *fakeProbe) Check(ctx context.Context) error { return p.err }
+
+const (
+	testThreshold   = 3
+	testOpenTimeout = 20 * time.Millisecond
+)
+
+func newTestBreakerCache(next ports.CachePort) *CircuitBreakerCache {
+	return NewCircuitBreakerCache(next, &fakeProbe{}, CircuitBreakerSettings{
+		FailureThreshold: testThreshold,
+		OpenTimeout:      testOpenTimeout,
+		ProbeInterval:    time.Millisecond,
+		ProbeTimeout:     time.Second,
+	}, testutil.NopLogger{}, testutil.NopMetrics{})
+}
+
+// failures makes n calls through c with the wrapped cache failing
+func failures(c *CircuitBreakerCache, next *flakyCache, n int) {
+	next.setErr(errCacheDown)
+	for i := 0; i < n; i++ {
+		_, _ = c.Get(context.Background(), "key")
+	}
+}
+
+func TestCircuitBreakerCache_States(t *testing.T) {
+	tests := []struct {
+		name string
+		// run drives the breaker, the wrapped cache fails until it clears the error
+		run       func(c *CircuitBreakerCache, next *flakyCache)
+		wantState breaker.State
+	}{
+		{
+			name: "stays closed below the threshold",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				failures(c, next, testThreshold-1)
+			},
+			wantState: breaker.Closed,
+		},
+		{
+			name: "opens at the threshold",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				failures(c, next, testThreshold)
+			},
+			wantState: breaker.Open,
+		},
+		{
+			name: "a success resets the count",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				failures(c, next, testThreshold-1)
+				next.setErr(nil)
+				_ = c.Set(context.Background(), "key", nil, time.Minute)
+				failures(c, next, testThreshold-1)
+			},
+			wantState: breaker.Closed,
+		},
+		{
+			name: "misses are not failures",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				next.setErr(ports.ErrCacheMiss)
+				for i := 0; i < testThreshold*2; i++ {
+					_, _ = c.Get(context.Background(), "key")
+				}
+			},
+			wantState: breaker.Closed,
+		},
+		{
+			name: "cancelled calls are not failures",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				next.setErr(context.Canceled)
+				for i := 0; i < testThreshold*2; i++ {
+					_, _ = c.Get(context.Background(), "key")
+				}
+			},
+			wantState: breaker.Closed,
+		},
+		{
+			name: "half-open after the open timeout",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				failures(c, next, testThreshold)
+				time.Sleep(2 * testOpenTimeout)
+			},
+			wantState: breaker.HalfOpen,
+		},
+		{
+			name: "a half-open success closes",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				failures(c, next, testThreshold)
+				time.Sleep(2 * testOpenTimeout)
+				next.setErr(nil)
+				_, _ = c.Get(context.Background(), "key")
+			},
+			wantState: breaker.Closed,
+		},
+		{
+			name: "a half-open failure opens again",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				failures(c, next, testThreshold)
+				time.Sleep(2 * testOpenTimeout)
+				_, _ = c.Get(context.Background(), "key")
+			},
+			wantState: breaker.Open,
+		},
+		{
+			name: "trip opens at once",
+			run: func(c *CircuitBreakerCache, next *flakyCache) {
+				c.Trip()
+			},
+			wantState: breaker.Open,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &flakyCache{}
+			c := newTestBreakerCache(next)
+
+			tt.run(c, next)
+
+			if got := c.State(); got != tt.wantState {
+				t.Errorf("state = %s, want %s", got, tt.wantState)
+			}
+			if got := c.HealthDetails()["circuit_breaker"]; got != tt.wantState.String() {
+				t.Errorf("health details report %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerCache_OpenFailsFast(t *testing.T) {
+	next := &flakyCache{}
+	c := newTestBreakerCache(next)
+	failures(c, next, testThreshold)
+	reached := next.callCount()
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{name: "get", call: func() error {
+			_, err := c.Get(context.Background(), "key")
+			return err
+		}},
+		{name: "set", call: func() error {
+			return c.Set(context.Background(), "key", []byte("value"), time.Minute)
+		}},
+		{name: "delete many", call: func() error {
+			return c.DeleteMany(context.Background(), "key")
+		}},
+		{name: "execute", call: func() error {
+			return c.Execute(func() error {
+				t.Error("Execute ran fn while the breaker is open")
+				return nil
+			})
+		}},
+	}
+
+	for _, tt := range calls {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, breaker.ErrOpen) {
+				t.Errorf("err = %v, want breaker.ErrOpen", err)
+			}
+		})
+	}
+
+	if got := next.callCount(); got != reached {
+		t.Errorf("wrapped cache got %d calls while open, want none", got-reached)
+	}
+}
+
+func TestCircuitBreakerCache_ExecuteRecordsOutcome(t *testing.T) {
+	c := newTestBreakerCache(&flakyCache{})
+
+	for i := 0; i < testThreshold; i++ {
+		if err := c.Execute(func() error { return errCacheDown }); !errors.Is(err, errCacheDown) {
+			t.Fatalf("call %d: err = %v, want the error of fn", i+1, err)
+		}
+	}
+	if got := c.State(); got != breaker.Open {
+		t.Errorf("state = %s, want open after failing Execute calls", got)
+	}
+}
+
+func TestCircuitBreakerCache_ProbeCloses(t *testing.T) {
+	next := &flakyCache{}
+	c := newTestBreakerCache(next)
+	c.Trip()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for c.State() != breaker.Closed {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want closed after a successful probe", c.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
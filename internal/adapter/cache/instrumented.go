@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// InstrumentedCache counts lookups of the wrapped cache by result
+type InstrumentedCache struct {
+	next    ports.CachePort
+	name    string
+	metrics ports.MetricsPort
+}
+
+func NewInstrumentedCache(next ports.CachePort, name string, metrics ports.MetricsPort) *InstrumentedCache {
+	return &InstrumentedCache{
+		next:    next,
+		name:    name,
+		metrics: metrics,
+	}
+}
+
+func (c *InstrumentedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.next.Get(ctx, key)
+	c.record(1, err)
+	return value, err
+}
+
+func (c *InstrumentedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return c.next.Set(ctx, key, value, ttl, tags...)
+}
+
+func (c *InstrumentedCache) Delete(ctx context.Context, key string) error {
+	return c.next.Delete(ctx, key)
+}
+
+func (c *InstrumentedCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	values, err := c.next.MGet(ctx, keys...)
+	if err != nil {
+		c.record(len(keys), err)
+		return values, err
+	}
+
+	c.add("hit", len(values))
+	c.add("miss", len(keys)-len(values))
+	return values, nil
+}
+
+func (c *InstrumentedCache) MSet(ctx context.Context, items ...ports.CacheItem) error {
+	return c.next.MSet(ctx, items...)
+}
+
+func (c *InstrumentedCache) DeleteMany(ctx context.Context, keys ...string) error {
+	return c.next.DeleteMany(ctx, keys...)
+}
+
+func (c *InstrumentedCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	return c.next.InvalidateTags(ctx, tags...)
+}
+
+// TagKeys keeps tag resolution available to the tiered cache
+func (c *InstrumentedCache) TagKeys(ctx context.Context, tags ...string) ([]string, error) {
+	resolver, ok := c.next.(ports.CacheTagResolver)
+	if !ok {
+		return nil, nil
+	}
+	return resolver.TagKeys(ctx, tags...)
+}
+
+func (c *InstrumentedCache) record(count int, err error) {
+	switch {
+	case err == nil:
+		c.add("hit", count)
+	case errors.Is(err, ports.ErrCacheMiss):
+		c.add("miss", count)
+	default:
+		c.add("error", count)
+	}
+}
+
+func (c *InstrumentedCache) add(result string, count int) {
+	labels := map[string]string{
+		"cache":  c.name,
+		"result": result,
+	}
+	for i := 0; i < count; i++ {
+		c.metrics.IncrementCounter(ports.MetricCacheRequests, labels)
+	}
+}
+
+var (
+	_ ports.CachePort        = (*InstrumentedCache)(nil)
+	_ ports.CacheTagResolver = (*InstrumentedCache)(nil)
+)
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// MemoryCache is an in-process LRU cache bounded by number of entries.
+// Expired entries are dropped lazily on access or evicted as least recently used
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      map[string]*list.Element
+	order      *list.List
+	tags       map[string]map[string]struct{}
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element, maxEntries),
+		order:      list.New(),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value, ok := m.get(key, time.Now())
+	if !ok {
+		return nil, ports.ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(ports.CacheItem{
+		Key:   key,
+		Value: value,
+		TTL:   ttl,
+		Tags:  tags,
+	})
+	return nil
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	return m.DeleteMany(ctx, key)
+}
+
+func (m *MemoryCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	found := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, ok := m.get(key, now); ok {
+			found[key] = value
+		}
+	}
+	return found, nil
+}
+
+func (m *MemoryCache) MSet(ctx context.Context, items ...ports.CacheItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, item := range items {
+		m.set(item)
+	}
+	return nil
+}
+
+func (m *MemoryCache) DeleteMany(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := m.items[key]; ok {
+			m.remove(element)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.tagKeys(tags) {
+		if element, ok := m.items[key]; ok {
+			m.remove(element)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCache) TagKeys(ctx context.Context, tags ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tagKeys(tags), nil
+}
+
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+func (m *MemoryCache) get(key string, now time.Time) ([]byte, bool) {
+	element, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(now) {
+		m.remove(element)
+		return nil, false
+	}
+
+	m.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (m *MemoryCache) set(item ports.CacheItem) {
+	if element, ok := m.items[item.Key]; ok {
+		m.remove(element)
+	}
+
+	entry := &memoryEntry{
+		key:   item.Key,
+		value: item.Value,
+		tags:  item.Tags,
+	}
+	if item.TTL > 0 {
+		entry.expiresAt = time.Now().Add(item.TTL)
+	}
+
+	m.items[item.Key] = m.order.PushFront(entry)
+	for _, tag := range item.Tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][item.Key] = struct{}{}
+	}
+
+	for m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *MemoryCache) remove(element *list.Element) {
+	entry := m.order.Remove(element).(*memoryEntry)
+	delete(m.items, entry.key)
+
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], entry.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+func (m *MemoryCache) tagKeys(tags []string) []string {
+	var keys []string
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+var (
+	_ ports.CachePort        = (*MemoryCache)(nil)
+	_ ports.CacheTagResolver = (*MemoryCache)(nil)
+)
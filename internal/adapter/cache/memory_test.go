@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+func TestMemoryCache_ExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(10)
+
+	_ = m.Set(ctx, "short", []byte("a"), 10*time.Millisecond)
+	_ = m.Set(ctx, "long", []byte("b"), time.Minute)
+	_ = m.Set(ctx, "forever", []byte("c"), 0)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := m.Get(ctx, "short"); !errors.Is(err, ports.ErrCacheMiss) {
+		t.Errorf("expired entry: err = %v, want ErrCacheMiss", err)
+	}
+	for _, key := range []string{"long", "forever"} {
+		if _, err := m.Get(ctx, key); err != nil {
+			t.Errorf("Get %s: %v", key, err)
+		}
+	}
+
+	found, _ := m.MGet(ctx, "short", "long")
+	if _, ok := found["short"]; ok || len(found) != 1 {
+		t.Errorf("MGet = %v, want only the live entry", found)
+	}
+	if got := m.Len(); got != 2 {
+		t.Errorf("Len = %d, want the expired entry dropped", got)
+	}
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(2)
+
+	_ = m.Set(ctx, "a", []byte("a"), time.Minute)
+	_ = m.Set(ctx, "b", []byte("b"), time.Minute)
+	// Reading a makes b the least recently used
+	_, _ = m.Get(ctx, "a")
+	_ = m.Set(ctx, "c", []byte("c"), time.Minute)
+
+	if _, err := m.Get(ctx, "b"); !errors.Is(err, ports.ErrCacheMiss) {
+		t.Errorf("b: err = %v, want it evicted", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := m.Get(ctx, key); err != nil {
+			t.Errorf("Get %s: %v", key, err)
+		}
+	}
+	if got := m.Len(); got != 2 {
+		t.Errorf("Len = %d, want 2", got)
+	}
+}
+
+func TestMemoryCache_Tags(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(2)
+
+	_ = m.Set(ctx, "user:1", []byte("a"), time.Minute, "user:1")
+	_ = m.Set(ctx, "user_email:a", []byte("a"), time.Minute, "user:1")
+
+	keys, _ := m.TagKeys(ctx, "user:1")
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"user:1", "user_email:a"}) {
+		t.Errorf("TagKeys = %v", keys)
+	}
+
+	// Evicted entries leave the tag index too
+	_ = m.Set(ctx, "other", []byte("b"), time.Minute)
+	if keys, _ := m.TagKeys(ctx, "user:1"); len(keys) != 1 {
+		t.Errorf("TagKeys after eviction = %v, want one key", keys)
+	}
+
+	_ = m.InvalidateTags(ctx, "user:1")
+	if got := m.Len(); got != 1 {
+		t.Errorf("Len = %d, want only the untagged entry", got)
+	}
+}
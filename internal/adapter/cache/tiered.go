@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// Invalidator broadcasts deleted keys to the other instances
+type Invalidator interface {
+	Publish(ctx context.Context, keys ...string) error
+	// Subscribe blocks and calls handler for keys deleted by other instances
+	Subscribe(ctx context.Context, handler func(keys []string)) error
+}
+
+// TieredCache reads from the local L1 first and falls back to the shared L2.
+// Deletes are broadcast so every replica drops its L1 copy, tag invalidations
+// are resolved to keys through the L2 tag index before broadcasting.
+// L1 entries live at most l1TTL, which bounds staleness when broadcasts are lost
+type TieredCache struct {
+	l1          ports.CachePort
+	l2          ports.CachePort
+	l1TTL       time.Duration
+	invalidator Invalidator
+	logger      ports.LoggerPort
+}
+
+func NewTieredCache(
+	l1 ports.CachePort,
+	l2 ports.CachePort,
+	l1TTL time.Duration,
+	invalidator Invalidator,
+	logger ports.LoggerPort,
+) *TieredCache {
+	return &TieredCache{
+		l1:          l1,
+		l2:          l2,
+		l1TTL:       l1TTL,
+		invalidator: invalidator,
+		logger:      logger,
+	}
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.l1.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	value, err := t.l2.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	t.fillL1(ctx, ports.CacheItem{
+		Key:   key,
+		Value: value,
+		TTL:   t.l1TTL,
+	})
+	return value, nil
+}
+
+func (t *TieredCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return t.MSet(ctx, ports.CacheItem{
+		Key:   key,
+		Value: value,
+		TTL:   ttl,
+		Tags:  tags,
+	})
+}
+
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	return t.DeleteMany(ctx, key)
+}
+
+func (t *TieredCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	found, err := t.l1.MGet(ctx, keys...)
+	if err != nil {
+		found = make(map[string][]byte, len(keys))
+	}
+
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	fromL2, err := t.l2.MGet(ctx, missing...)
+	if err != nil {
+		return found, err
+	}
+
+	fill := make([]ports.CacheItem, 0, len(fromL2))
+	for key, value := range fromL2 {
+		found[key] = value
+		fill = append(fill, ports.CacheItem{
+			Key:   key,
+			Value: value,
+			TTL:   t.l1TTL,
+		})
+	}
+	t.fillL1(ctx, fill...)
+
+	return found, nil
+}
+
+func (t *TieredCache) MSet(ctx context.Context, items ...ports.CacheItem) error {
+	l1Items := make([]ports.CacheItem, 0, len(items))
+	for _, item := range items {
+		l1Item := item
+		if item.TTL <= 0 || item.TTL > t.l1TTL {
+			l1Item.TTL = t.l1TTL
+		}
+		l1Items = append(l1Items, l1Item)
+	}
+
+	t.fillL1(ctx, l1Items...)
+	return t.l2.MSet(ctx, items...)
+}
+
+func (t *TieredCache) DeleteMany(ctx context.Context, keys ...string) error {
+	l1Err := t.l1.DeleteMany(ctx, keys...)
+	l2Err := t.l2.DeleteMany(ctx, keys...)
+	publishErr := t.invalidator.Publish(ctx, keys...)
+
+	return errors.Join(l1Err, l2Err, publishErr)
+}
+
+func (t *TieredCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	keys, err := t.TagKeys(ctx, tags...)
+	if err != nil {
+		// Tag index is unavailable, at least this instance drops its copies
+		t.logger.Warn("Failed to resolve cache tags", map[string]interface{}{
+			"error": err.Error(),
+			"tags":  tags,
+		})
+	}
+
+	l1Err := t.l1.InvalidateTags(ctx, tags...)
+	if len(keys) > 0 {
+		l1Err = errors.Join(l1Err, t.l1.DeleteMany(ctx, keys...))
+	}
+	l2Err := t.l2.InvalidateTags(ctx, tags...)
+	publishErr := t.invalidator.Publish(ctx, keys...)
+
+	return errors.Join(l1Err, l2Err, publishErr)
+}
+
+// TagKeys resolves tags through the shared L2 index
+func (t *TieredCache) TagKeys(ctx context.Context, tags ...string) ([]string, error) {
+	resolver, ok := t.l2.(ports.CacheTagResolver)
+	if !ok {
+		return nil, nil
+	}
+	return resolver.TagKeys(ctx, tags...)
+}
+
+// Run listens for invalidations from other instances until ctx is done
+func (t *TieredCache) Run(ctx context.Context) {
+	for {
+		err := t.invalidator.Subscribe(ctx, func(keys []string) {
+			if err := t.l1.DeleteMany(ctx, keys...); err != nil {
+				t.logger.Warn("Failed to invalidate L1 cache", map[string]interface{}{
+					"error": err.Error(),
+					"keys":  keys,
+				})
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			t.logger.Warn("Cache invalidation subscription failed, retrying", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (t *TieredCache) fillL1(ctx context.Context, items ...ports.CacheItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	if err := t.l1.MSet(ctx, items...); err != nil {
+		t.logger.Warn("Failed to fill L1 cache", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+var (
+	_ ports.CachePort        = (*TieredCache)(nil)
+	_ ports.CacheTagResolver = (*TieredCache)(nil)
+)
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/breaker"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+)
+
+// fakeInvalidator records published keys, deliver plays a message from another instance
+type fakeInvalidator struct {
+	mu        sync.Mutex
+	published [][]string
+	handler   func(keys []string)
+	ready     chan struct{}
+}
+
+func newFakeInvalidator() *fakeInvalidator {
+	return &fakeInvalidator{ready: make(chan struct{})}
+}
+
+func (i *fakeInvalidator) Publish(ctx context.Context, keys ...string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(keys) > 0 {
+		i.published = append(i.published, keys)
+	}
+	return nil
+}
+
+func (i *fakeInvalidator) Subscribe(ctx context.Context, handler func(keys []string)) error {
+	i.mu.Lock()
+	i.handler = handler
+	i.mu.Unlock()
+	close(i.ready)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (i *fakeInvalidator) deliver(keys ...string) {
+	<-i.ready
+
+	i.mu.Lock()
+	handler := i.handler
+	i.mu.Unlock()
+	handler(keys)
+}
+
+func (i *fakeInvalidator) publishedKeys() [][]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.published
+}
+
+// While Redis is down a tiered cache keeps serving what its L1 holds
+func TestTieredCache_ServesL1WhileL2BreakerOpen(t *testing.T) {
+	next := &flakyCache{}
+	l2 := newTestBreakerCache(next)
+	tiered := NewTieredCache(NewMemoryCache(10), l2, time.Minute, newFakeInvalidator(), testutil.NopLogger{})
+
+	if err := tiered.Set(context.Background(), "user:1", []byte("cached"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	failures(l2, next, testThreshold)
+
+	value, err := tiered.Get(context.Background(), "user:1")
+	if err != nil || string(value) != "cached" {
+		t.Errorf("Get = %q, %v, want the L1 copy", value, err)
+	}
+	if _, err := tiered.Get(context.Background(), "user:2"); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Get of an L1 miss: err = %v, want breaker.ErrOpen", err)
+	}
+}
+
+func newTestTieredCache(l1TTL time.Duration) (*TieredCache, *MemoryCache, *MemoryCache, *fakeInvalidator) {
+	l1, l2 := NewMemoryCache(10), NewMemoryCache(10)
+	invalidator := newFakeInvalidator()
+	return NewTieredCache(l1, l2, l1TTL, invalidator, testutil.NopLogger{}), l1, l2, invalidator
+}
+
+func TestTieredCache_GetBackfillsL1(t *testing.T) {
+	ctx := context.Background()
+	tiered, l1, l2, _ := newTestTieredCache(10 * time.Millisecond)
+
+	_ = l2.Set(ctx, "user:1", []byte("from l2"), time.Minute)
+
+	value, err := tiered.Get(ctx, "user:1")
+	if err != nil || string(value) != "from l2" {
+		t.Fatalf("Get = %q, %v, want the L2 value", value, err)
+	}
+	if value, err := l1.Get(ctx, "user:1"); err != nil || string(value) != "from l2" {
+		t.Errorf("L1 = %q, %v, want it backfilled", value, err)
+	}
+
+	// The L1 copy lives l1TTL, the L2 one is still there afterwards
+	time.Sleep(20 * time.Millisecond)
+	if _, err := l1.Get(ctx, "user:1"); !errors.Is(err, ports.ErrCacheMiss) {
+		t.Errorf("L1 after l1TTL: err = %v, want ErrCacheMiss", err)
+	}
+	if _, err := tiered.Get(ctx, "user:1"); err != nil {
+		t.Errorf("Get after l1TTL: %v", err)
+	}
+
+	if _, err := tiered.Get(ctx, "user:2"); !errors.Is(err, ports.ErrCacheMiss) {
+		t.Errorf("Get of a missing key: err = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestTieredCache_MGetMergesTiers(t *testing.T) {
+	ctx := context.Background()
+	tiered, l1, l2, _ := newTestTieredCache(time.Minute)
+
+	_ = l1.Set(ctx, "a", []byte("l1"), time.Minute)
+	_ = l2.Set(ctx, "a", []byte("l2"), time.Minute)
+	_ = l2.Set(ctx, "b", []byte("l2"), time.Minute)
+
+	found, err := tiered.MGet(ctx, "a", "b", "c")
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	want := map[string]string{"a": "l1", "b": "l2"}
+	if len(found) != len(want) {
+		t.Errorf("MGet = %v, want %v", found, want)
+	}
+	for key, value := range want {
+		if string(found[key]) != value {
+			t.Errorf("%s = %q, want %q", key, found[key], value)
+		}
+	}
+	if _, err := l1.Get(ctx, "b"); err != nil {
+		t.Errorf("L1 b: %v, want it backfilled", err)
+	}
+}
+
+func TestTieredCache_SetCapsL1TTL(t *testing.T) {
+	ctx := context.Background()
+	tiered, l1, l2, _ := newTestTieredCache(10 * time.Millisecond)
+
+	if err := tiered.Set(ctx, "user:1", []byte("v"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := l1.Get(ctx, "user:1"); !errors.Is(err, ports.ErrCacheMiss) {
+		t.Errorf("L1: err = %v, want it expired after l1TTL", err)
+	}
+	if _, err := l2.Get(ctx, "user:1"); err != nil {
+		t.Errorf("L2: %v, want the full TTL", err)
+	}
+}
+
+func TestTieredCache_DeleteBroadcasts(t *testing.T) {
+	ctx := context.Background()
+	tiered, l1, l2, invalidator := newTestTieredCache(time.Minute)
+
+	_ = tiered.Set(ctx, "user:1", []byte("v"), time.Minute)
+	if err := tiered.Delete(ctx, "user:1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	for name, tier := range map[string]*MemoryCache{"L1": l1, "L2": l2} {
+		if _, err := tier.Get(ctx, "user:1"); !errors.Is(err, ports.ErrCacheMiss) {
+			t.Errorf("%s: err = %v, want the key deleted", name, err)
+		}
+	}
+	if got := invalidator.publishedKeys(); len(got) != 1 || !slices.Equal(got[0], []string{"user:1"}) {
+		t.Errorf("published %v, want [[user:1]]", got)
+	}
+}
+
+func TestTieredCache_InvalidateTagsResolvesThroughL2(t *testing.T) {
+	ctx := context.Background()
+	tiered, l1, _, invalidator := newTestTieredCache(time.Minute)
+
+	_ = tiered.Set(ctx, "user:1", []byte("v"), time.Minute, "user:1")
+	_ = tiered.Set(ctx, "user_email:a", []byte("v"), time.Minute, "user:1")
+	_ = tiered.Set(ctx, "other", []byte("v"), time.Minute)
+	// An L1 copy filled from L2 carries no tags
+	_ = l1.DeleteMany(ctx, "user_email:a")
+	_, _ = tiered.Get(ctx, "user_email:a")
+
+	if err := tiered.InvalidateTags(ctx, "user:1"); err != nil {
+		t.Fatalf("InvalidateTags: %v", err)
+	}
+
+	for _, key := range []string{"user:1", "user_email:a"} {
+		if _, err := tiered.Get(ctx, key); !errors.Is(err, ports.ErrCacheMiss) {
+			t.Errorf("%s: err = %v, want it invalidated", key, err)
+		}
+	}
+	if _, err := tiered.Get(ctx, "other"); err != nil {
+		t.Errorf("other: %v, want it kept", err)
+	}
+
+	published := invalidator.publishedKeys()
+	if len(published) != 1 {
+		t.Fatalf("published %v, want one message", published)
+	}
+	keys := slices.Clone(published[0])
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"user:1", "user_email:a"}) {
+		t.Errorf("published keys %v, want the tagged keys", keys)
+	}
+}
+
+func TestTieredCache_RunDropsInvalidatedL1Keys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tiered, l1, l2, invalidator := newTestTieredCache(time.Minute)
+
+	_ = tiered.Set(ctx, "user:1", []byte("v"), time.Minute)
+	_ = tiered.Set(ctx, "user:2", []byte("v"), time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		tiered.Run(ctx)
+		close(done)
+	}()
+
+	// Another instance changed user:1 and already cleaned L2
+	_ = l2.Delete(ctx, "user:1")
+	invalidator.deliver("user:1")
+
+	if _, err := l1.Get(ctx, "user:1"); !errors.Is(err, ports.ErrCacheMiss) {
+		t.Errorf("L1 user:1: err = %v, want it dropped", err)
+	}
+	if _, err := l1.Get(ctx, "user:2"); err != nil {
+		t.Errorf("L1 user:2: %v, want it kept", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+
+	// Bumped together with schema/user-event.v1.json on breaking payload changes
+	UserEventSchemaVersion = "v1"
+	UserEventSchemaID      = "urn:webike:schema:user-event:" + UserEventSchemaVersion
+)
+
+// UserEventSchema is the JSON schema of the data of user events
+//
+//go:embed schema/user-event.v1.json
+var UserEventSchema []byte
+
+// CloudEvent is a CloudEvents 1.0 envelope in structured JSON mode
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// NewCloudEvent wraps event, the event ID is kept so consumers can deduplicate
+func NewCloudEvent(source string, event domain.Event) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              event.ID.String(),
+		Source:          source,
+		Type:            cloudEventType(event.Type),
+		Subject:         event.AggregateID.String(),
+		Time:            event.OccurredAt.UTC(),
+		DataContentType: "application/json",
+		DataSchema:      UserEventSchemaID,
+		Data:            event.Payload,
+	}
+}
+
+// e.g. com.webike.user.registered.v1
+func cloudEventType(eventType domain.EventType) string {
+	return fmt.Sprintf("com.webike.%s.%s", eventType, UserEventSchemaVersion)
+}
+
+func encodeCloudEvent(source string, event domain.Event) ([]byte, error) {
+	data, err := json.Marshal(NewCloudEvent(source, event))
+	if err != nil {
+		return nil, fmt.Errorf("encode event %s: %w", event.ID, err)
+	}
+	return data, nil
+}
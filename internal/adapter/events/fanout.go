@@ -0,0 +1,33 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// FanoutPublisher hands every event to all publishers. When one fails the
+// relay retries the event, so the others see it again and must deduplicate
+type FanoutPublisher struct {
+	publishers []ports.EventPublisher
+}
+
+func NewFanoutPublisher(publishers ...ports.EventPublisher) *FanoutPublisher {
+	return &FanoutPublisher{
+		publishers: publishers,
+	}
+}
+
+func (p *FanoutPublisher) Publish(ctx context.Context, event domain.Event) error {
+	var errs []error
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var _ ports.EventPublisher = (*FanoutPublisher)(nil)
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type KafkaSettings struct {
+	Brokers        []string
+	Topic          string
+	Source         string
+	PublishTimeout time.Duration
+}
+
+// KafkaPublisher publishes CloudEvents to a Kafka topic.
+// Events are keyed by user ID, so the events of one user share a partition
+// and keep their order. Publish returns after all in-sync replicas acknowledged
+type KafkaPublisher struct {
+	writer   *kafka.Writer
+	settings KafkaSettings
+	metrics  ports.MetricsPort
+}
+
+func NewKafkaPublisher(settings KafkaSettings, metrics ports.MetricsPort) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(settings.Brokers...),
+			Topic:        settings.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// The relay publishes one event at a time and waits for the ack
+			BatchSize:    1,
+			WriteTimeout: settings.PublishTimeout,
+		},
+		settings: settings,
+		metrics:  metrics,
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event domain.Event) (err error) {
+	start := time.Now()
+	defer func() { recordPublish(p.metrics, "kafka", event, start, err) }()
+
+	data, err := encodeCloudEvent(p.settings.Source, event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.settings.PublishTimeout)
+	defer cancel()
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.AggregateID.String()),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte(cloudEventsContentType)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("publish event %s to kafka: %w", event.ID, err)
+	}
+	return nil
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+var _ ports.EventPublisher = (*KafkaPublisher)(nil)
@@ -0,0 +1,30 @@
+package events
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// LogPublisher only logs events, used when no broker is configured
+type LogPublisher struct {
+	logger ports.LoggerPort
+}
+
+func NewLogPublisher(logger ports.LoggerPort) *LogPublisher {
+	return &LogPublisher{
+		logger: logger,
+	}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event domain.Event) error {
+	p.logger.InfoContext(ctx, "Event published", map[string]interface{}{
+		"event_id":     event.ID.String(),
+		"type":         string(event.Type),
+		"aggregate_id": event.AggregateID.String(),
+	})
+	return nil
+}
+
+var _ ports.EventPublisher = (*LogPublisher)(nil)
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+func recordPublish(metrics ports.MetricsPort, transport string, event domain.Event, start time.Time, err error) {
+	outcome := ports.OutcomeSuccess
+	if err != nil {
+		outcome = ports.OutcomeError
+	}
+
+	metrics.IncrementCounter(ports.MetricEventsPublished, map[string]string{
+		"transport": transport,
+		"type":      string(event.Type),
+		"outcome":   outcome,
+	})
+	metrics.RecordDuration(ports.MetricEventPublishDuration, time.Since(start), map[string]string{
+		"transport": transport,
+	})
+}
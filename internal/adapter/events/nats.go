@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type NATSSettings struct {
+	URL    string
+	Stream string
+	// Events go to <prefix>.<event type>, e.g. webike.user.registered
+	SubjectPrefix  string
+	Source         string
+	PublishTimeout time.Duration
+}
+
+// NATSPublisher publishes CloudEvents to a JetStream stream.
+// Publish returns after the stream acknowledged the event, the event ID
+// is the message ID so retries inside the dedup window are dropped
+type NATSPublisher struct {
+	conn     *nats.Conn
+	js       jetstream.JetStream
+	settings NATSSettings
+	logger   ports.LoggerPort
+	metrics  ports.MetricsPort
+}
+
+func NewNATSPublisher(ctx context.Context, settings NATSSettings, logger ports.LoggerPort, metrics ports.MetricsPort) (*NATSPublisher, error) {
+	conn, err := nats.Connect(settings.URL,
+		nats.Name(settings.Source),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create jetstream context: %w", err)
+	}
+
+	p := &NATSPublisher{
+		conn:     conn,
+		js:       js,
+		settings: settings,
+		logger:   logger,
+		metrics:  metrics,
+	}
+	if err := p.ensureStream(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// ensureStream creates the stream when it is missing,
+// an existing one is left as configured by operations
+func (p *NATSPublisher) ensureStream(ctx context.Context) error {
+	_, err := p.js.Stream(ctx, p.settings.Stream)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, jetstream.ErrStreamNotFound) {
+		return fmt.Errorf("get stream %s: %w", p.settings.Stream, err)
+	}
+
+	_, err = p.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:       p.settings.Stream,
+		Subjects:   []string{p.settings.SubjectPrefix + ".>"},
+		Storage:    jetstream.FileStorage,
+		Duplicates: 2 * time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("create stream %s: %w", p.settings.Stream, err)
+	}
+
+	p.logger.Info("Created NATS stream", map[string]interface{}{
+		"stream": p.settings.Stream,
+	})
+	return nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event domain.Event) (err error) {
+	start := time.Now()
+	defer func() { recordPublish(p.metrics, "nats", event, start, err) }()
+
+	data, err := encodeCloudEvent(p.settings.Source, event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(fmt.Sprintf("%s.%s", p.settings.SubjectPrefix, event.Type))
+	msg.Data = data
+	msg.Header.Set("Content-Type", cloudEventsContentType)
+
+	ctx, cancel := context.WithTimeout(ctx, p.settings.PublishTimeout)
+	defer cancel()
+
+	ack, err := p.js.PublishMsg(ctx, msg,
+		jetstream.WithMsgID(event.ID.String()),
+		jetstream.WithExpectStream(p.settings.Stream),
+	)
+	if err != nil {
+		return fmt.Errorf("publish event %s to nats: %w", event.ID, err)
+	}
+
+	if ack.Duplicate {
+		p.logger.InfoContext(ctx, "NATS dropped a duplicate event", map[string]interface{}{
+			"event_id": event.ID.String(),
+		})
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the connection
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
+
+var _ ports.EventPublisher = (*NATSPublisher)(nil)
@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// runJetStream starts an in-process server with JetStream in a temporary directory
+func runJetStream(t *testing.T) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("create nats server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		srv.Shutdown()
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(func() {
+		srv.Shutdown()
+		srv.WaitForShutdown()
+	})
+	return srv
+}
+
+func TestNATSPublisher_PublishCloudEvent(t *testing.T) {
+	srv := runJetStream(t)
+	ctx := context.Background()
+
+	settings := NATSSettings{
+		URL:            srv.ClientURL(),
+		Stream:         "WEBIKE_USERS",
+		SubjectPrefix:  "webike",
+		Source:         "/webike/user-service",
+		PublishTimeout: 5 * time.Second,
+	}
+	publisher, err := NewNATSPublisher(ctx, settings, testutil.NopLogger{}, testutil.NopMetrics{})
+	if err != nil {
+		t.Fatalf("NewNATSPublisher: %v", err)
+	}
+	defer publisher.Close()
+
+	event := domain.Event{
+		ID:          uuid.New(),
+		Type:        domain.EventUserRegistered,
+		AggregateID: uuid.New(),
+		OccurredAt:  time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Payload:     json.RawMessage(`{"id":"42","email":"rider@example.com"}`),
+	}
+
+	// The second publish is a retry inside the dedup window
+	for i := 0; i < 2; i++ {
+		if err := publisher.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish #%d: %v", i+1, err)
+		}
+	}
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close()
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+	stream, err := js.Stream(ctx, settings.Stream)
+	if err != nil {
+		t.Fatalf("stream %s was not created: %v", settings.Stream, err)
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("stream holds %d messages, want 1 after a duplicate publish", info.State.Msgs)
+	}
+
+	msg, err := stream.GetLastMsgForSubject(ctx, "webike.user.registered")
+	if err != nil {
+		t.Fatalf("get message: %v", err)
+	}
+	if got := msg.Header.Get(jetstream.MsgIDHeader); got != event.ID.String() {
+		t.Errorf("%s = %q, want %q", jetstream.MsgIDHeader, got, event.ID)
+	}
+	if got := msg.Header.Get("Content-Type"); got != cloudEventsContentType {
+		t.Errorf("Content-Type = %q, want %q", got, cloudEventsContentType)
+	}
+
+	var envelope CloudEvent
+	if err := json.Unmarshal(msg.Data, &envelope); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	want := CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              event.ID.String(),
+		Source:          settings.Source,
+		Type:            "com.webike.user.registered.v1",
+		Subject:         event.AggregateID.String(),
+		Time:            event.OccurredAt,
+		DataContentType: "application/json",
+		DataSchema:      UserEventSchemaID,
+	}
+	data := envelope.Data
+	envelope.Data = nil
+	if !envelope.Time.Equal(want.Time) {
+		t.Errorf("time = %v, want %v", envelope.Time, want.Time)
+	}
+	envelope.Time = want.Time
+	if !reflect.DeepEqual(envelope, want) {
+		t.Errorf("envelope = %+v, want %+v", envelope, want)
+	}
+	if string(data) != string(event.Payload) {
+		t.Errorf("data = %s, want %s", data, event.Payload)
+	}
+}
@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+	userv1 "github.com/sm8ta/webike_user_microservice_nikita/proto/user/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type authServer struct {
+	userv1.UnimplementedAuthServiceServer
+	authService ports.AuthService
+}
+
+func (s *authServer) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	token, user, err := s.authService.Login(ctx, req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.LoginResponse{
+		Token: token,
+		User:  toProtoUser(user),
+	}, nil
+}
+
+// VerifyToken lets other services check a user's token without sharing the secret.
+// Like POST /introspect it needs a service token with the tokens:introspect scope,
+// and rejects revoked tokens and tokens of changed accounts
+func (s *authServer) VerifyToken(ctx context.Context, req *userv1.VerifyTokenRequest) (*userv1.VerifyTokenResponse, error) {
+	caller, ok := requestctx.Payload(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if !caller.IsService() || !caller.HasScope(domain.ScopeTokensIntrospect) {
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
+
+	result, err := s.authService.Introspect(ctx, req.GetToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if !result.Active {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+
+	resp := &userv1.VerifyTokenResponse{
+		TokenId:   result.TokenID.String(),
+		Principal: string(result.Principal),
+	}
+	if result.Principal == domain.PrincipalService {
+		resp.ClientId = result.Subject.String()
+		resp.Scopes = result.Scopes
+	} else {
+		resp.UserId = result.Subject.String()
+		resp.Role = string(result.Role)
+	}
+	return resp, nil
+}
@@ -0,0 +1,21 @@
+package grpc
+
+import (
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	userv1 "github.com/sm8ta/webike_user_microservice_nikita/proto/user/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Never includes the password hash
+func toProtoUser(user *domain.User) *userv1.User {
+	return &userv1.User{
+		Id:          user.ID.String(),
+		Name:        user.Name,
+		Email:       user.Email,
+		DateOfBirth: user.DateOfBirth,
+		Role:        string(user.Role),
+		CreatedAt:   timestamppb.New(user.CreatedAt),
+		UpdatedAt:   timestamppb.New(user.UpdatedAt),
+	}
+}
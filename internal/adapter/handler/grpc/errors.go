@@ -0,0 +1,35 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps domain errors to gRPC codes, unknown errors are not exposed
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, domain.ErrInvalidID), errors.Is(err, domain.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrEmailAlreadyExists):
+		return status.Error(codes.AlreadyExists, "email already registered")
+	case errors.Is(err, domain.ErrDeletionInProgress):
+		return status.Error(codes.FailedPrecondition, "user deletion in progress")
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	case errors.Is(err, domain.ErrAuthUnavailable):
+		return status.Error(codes.Unavailable, "authentication temporarily unavailable")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
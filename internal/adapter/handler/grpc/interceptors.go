@@ -0,0 +1,167 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+	userv1 "github.com/sm8ta/webike_user_microservice_nikita/proto/user/v1"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationMetadataKey = "authorization"
+	requestIDMetadataKey     = "x-request-id"
+	authorizationType        = "bearer"
+)
+
+// Methods callable without a token
+var publicMethods = map[string]bool{
+	userv1.UserService_Register_FullMethodName: true,
+	userv1.AuthService_Login_FullMethodName:    true,
+}
+
+// RecoveryInterceptor turns a panic in a handler into codes.Internal
+func RecoveryInterceptor(logger ports.LoggerPort) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorGRPC(ctx, "Panic in gRPC handler", map[string]interface{}{
+					"method": info.FullMethod,
+					"panic":  r,
+					"stack":  string(debug.Stack()),
+				})
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RequestContextInterceptor stores request ID, method and client in the context,
+// like RequestIDMiddleware does for HTTP
+func RequestContextInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		requestID := firstValue(md, requestIDMetadataKey)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, requestID))
+
+		client := requestctx.Client{UserAgent: firstValue(md, "user-agent")}
+		if p, ok := peer.FromContext(ctx); ok {
+			client.IP = hostOnly(p.Addr.String())
+		}
+
+		ctx = requestctx.WithRequestID(ctx, requestID)
+		ctx = requestctx.WithRoute(ctx, info.FullMethod)
+		ctx = requestctx.WithClient(ctx, client)
+		return handler(ctx, req)
+	}
+}
+
+func LoggingInterceptor(logger ports.LoggerPort) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		fields := map[string]interface{}{
+			"method":      info.FullMethod,
+			"code":        status.Code(err).String(),
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		switch status.Code(err) {
+		case codes.OK:
+			logger.InfoGRPC(ctx, "gRPC request", fields)
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			fields["error"] = err.Error()
+			logger.ErrorGRPC(ctx, "gRPC request failed", fields)
+		default:
+			fields["error"] = err.Error()
+			logger.WarnGRPC(ctx, "gRPC request rejected", fields)
+		}
+		return resp, err
+	}
+}
+
+func MetricsInterceptor(metrics ports.MetricsPort) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		labels := map[string]string{
+			"method": info.FullMethod,
+			"code":   status.Code(err).String(),
+		}
+		metrics.IncrementCounter(ports.MetricGRPCRequests, labels)
+		metrics.RecordDuration(ports.MetricGRPCRequestDuration, time.Since(start), labels)
+		return resp, err
+	}
+}
+
+// AuthInterceptor verifies the bearer token of every method of our services
+// except the public ones. Health and reflection are left open
+func AuthInterceptor(authService ports.AuthService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if publicMethods[info.FullMethod] || !strings.HasPrefix(info.FullMethod, "/user.v1.") {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		fields := strings.Fields(firstValue(md, authorizationMetadataKey))
+		if len(fields) != 2 || strings.ToLower(fields[0]) != authorizationType {
+			return nil, status.Error(codes.Unauthenticated, "bearer token required")
+		}
+
+		payload, err := authService.Authenticate(ctx, fields[1])
+		if errors.Is(err, domain.ErrAuthUnavailable) {
+			return nil, status.Error(codes.Unavailable, "authentication temporarily unavailable")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+		}
+
+		return handler(requestctx.WithPayload(ctx, &payload), req)
+	}
+}
+
+// authorizeUser allows admins, the user themselves and service clients with the scope
+func authorizeUser(ctx context.Context, userID string, scope string) error {
+	payload, ok := requestctx.Payload(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if !payload.CanAccessUser(userID, scope) {
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+	return nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
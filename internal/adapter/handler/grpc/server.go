@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/config"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	userv1 "github.com/sm8ta/webike_user_microservice_nikita/proto/user/v1"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+}
+
+func NewServer(
+	config *config.GRPC,
+	userService ports.UserService,
+	authService ports.AuthService,
+	tokenService ports.TokenService,
+	logger ports.LoggerPort,
+	metrics ports.MetricsPort,
+) *Server {
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			RecoveryInterceptor(logger),
+			RequestContextInterceptor(),
+			LoggingInterceptor(logger),
+			MetricsInterceptor(metrics),
+			AuthInterceptor(authService),
+		),
+	)
+
+	userv1.RegisterUserServiceServer(server, &userServer{
+		userService:  userService,
+		tokenService: tokenService,
+	})
+	userv1.RegisterAuthServiceServer(server, &authServer{
+		authService: authService,
+	})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	healthServer.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(userv1.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	if config.Reflection {
+		reflection.Register(server)
+	}
+
+	return &Server{
+		server: server,
+		health: healthServer,
+	}
+}
+
+func (s *Server) Serve(listenAddr string) error {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return err
+	}
+	return s.server.Serve(listener)
+}
+
+// SetShuttingDown makes health checks report NOT_SERVING so clients move away
+func (s *Server) SetShuttingDown() {
+	s.health.Shutdown()
+}
+
+// Shutdown waits for in-flight calls until ctx is done
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+}
@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	userv1 "github.com/sm8ta/webike_user_microservice_nikita/proto/user/v1"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxBatchGetUsers = 100
+
+type userServer struct {
+	userv1.UnimplementedUserServiceServer
+	userService  ports.UserService
+	tokenService ports.TokenService
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	if err := authorizeUser(ctx, req.GetId(), domain.ScopeUsersRead); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUser(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+// BatchGetUsers is meant for service clients with users:read, regular users may only ask for themselves
+func (s *userServer) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	if len(req.GetIds()) > maxBatchGetUsers {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids per request", maxBatchGetUsers)
+	}
+	for _, id := range req.GetIds() {
+		if err := authorizeUser(ctx, id, domain.ScopeUsersRead); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &userv1.BatchGetUsersResponse{}
+	seen := make(map[string]bool, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		user, err := s.userService.GetUser(ctx, id)
+		if err != nil {
+			if code := status.Code(toStatus(err)); code == codes.NotFound || code == codes.InvalidArgument {
+				resp.MissingIds = append(resp.MissingIds, id)
+				continue
+			}
+			return nil, toStatus(err)
+		}
+		resp.Users = append(resp.Users, toProtoUser(user))
+	}
+	return resp, nil
+}
+
+func (s *userServer) Register(ctx context.Context, req *userv1.RegisterRequest) (*userv1.RegisterResponse, error) {
+	user, err := s.userService.Register(ctx, &domain.User{
+		Name:        req.GetName(),
+		DateOfBirth: req.GetDateOfBirth(),
+		Email:       req.GetEmail(),
+		Password:    req.GetPassword(),
+		Role:        domain.AppUser,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	token, err := s.tokenService.CreateToken(user)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to generate token")
+	}
+
+	return &userv1.RegisterResponse{
+		User:  toProtoUser(user),
+		Token: token,
+	}, nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error) {
+	if err := authorizeUser(ctx, req.GetId(), domain.ScopeUsersWrite); err != nil {
+		return nil, err
+	}
+
+	// Same semantics as the HTTP API: empty fields are left unchanged
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+	}
+	user := &domain.User{
+		ID:          id,
+		Name:        req.GetName(),
+		DateOfBirth: req.GetDateOfBirth(),
+		Email:       req.GetEmail(),
+		Password:    req.GetPassword(),
+	}
+
+	updated, err := s.userService.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.UpdateUserResponse{User: toProtoUser(updated)}, nil
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+	if err := authorizeUser(ctx, req.GetId(), domain.ScopeUsersWrite); err != nil {
+		return nil, err
+	}
+
+	deletion, err := s.userService.DeleteUser(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.DeleteUserResponse{
+		DeletionId: deletion.ID.String(),
+		Status:     string(deletion.Status),
+	}, nil
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"errors"
+	"strings"
 	"time"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
@@ -58,10 +59,30 @@ func (j *JWTTokenService) CreateToken(user *domain.User) (string, error) {
 	return token.SignedString(j.secretKey)
 }
 
+// CreateServiceToken issues a token for a machine client, scopes are space separated as in OAuth2
+func (j *JWTTokenService) CreateServiceToken(client *domain.ServiceClient, scopes []string, ttl time.Duration) (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	issuedAt := time.Now()
+	claims := jwt.MapClaims{
+		"id":        id.String(),
+		"typ":       string(domain.PrincipalService),
+		"client_id": client.ID.String(),
+		"scope":     strings.Join(scopes, " "),
+		"iat":       issuedAt.Unix(),
+		"exp":       issuedAt.Add(ttl).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(j.secretKey)
+}
+
 func (j *JWTTokenService) VerifyToken(token string) (domain.TokenPayload, error) {
 	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 		return j.secretKey, nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 	if err != nil {
 		j.logger.Error("Failed to parse jwt", map[string]interface{}{
 			"error":  err.Error(),
@@ -87,6 +108,10 @@ func (j *JWTTokenService) VerifyToken(token string) (domain.TokenPayload, error)
 		return domain.TokenPayload{}, errors.New("invalid parse id")
 	}
 
+	if typ, _ := claims["typ"].(string); typ == string(domain.PrincipalService) {
+		return j.servicePayload(id, claims)
+	}
+
 	userIDStr, ok := claims["user_id"].(string)
 	if !ok {
 		return domain.TokenPayload{}, errors.New("invalid user_id claims")
@@ -102,7 +127,7 @@ func (j *JWTTokenService) VerifyToken(token string) (domain.TokenPayload, error)
 	}
 
 	role := domain.UserRole(roleClaimed)
-	if role != domain.Admin && role != domain.AppUser {
+	if !role.Valid() {
 		j.logger.Warn("Invalid role in token", map[string]interface{}{
 			"role":   roleClaimed,
 			"method": "VerifyToken",
@@ -111,10 +136,44 @@ func (j *JWTTokenService) VerifyToken(token string) (domain.TokenPayload, error)
 	}
 
 	payload := domain.TokenPayload{
-		ID:     id,
-		UserID: userID,
-		Role:   role,
+		ID:        id,
+		Principal: domain.PrincipalUser,
+		UserID:    userID,
+		Role:      role,
+	}
+	setTimes(&payload, claims)
+
+	return payload, nil
+}
+
+func (j *JWTTokenService) servicePayload(id uuid.UUID, claims jwt.MapClaims) (domain.TokenPayload, error) {
+	clientIDStr, ok := claims["client_id"].(string)
+	if !ok {
+		return domain.TokenPayload{}, errors.New("invalid client_id claims")
+	}
+	clientID, err := uuid.Parse(clientIDStr)
+	if err != nil {
+		return domain.TokenPayload{}, errors.New("invalid parse client_id")
+	}
+
+	scope, _ := claims["scope"].(string)
+
+	payload := domain.TokenPayload{
+		ID:        id,
+		Principal: domain.PrincipalService,
+		ClientID:  clientID,
+		Scopes:    strings.Fields(scope),
 	}
+	setTimes(&payload, claims)
 
 	return payload, nil
 }
+
+func setTimes(payload *domain.TokenPayload, claims jwt.MapClaims) {
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		payload.IssuedAt = issuedAt.Time
+	}
+	if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+		payload.ExpiresAt = expiresAt.Time
+	}
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AuditHandler struct {
+	auditService ports.AuditPort
+	logger       ports.LoggerPort
+}
+
+type AuditEventsQuery struct {
+	ActorID  string    `form:"actor_id"`
+	TargetID string    `form:"target_id"`
+	Action   string    `form:"action"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit    int       `form:"limit"`
+	Offset   int       `form:"offset"`
+}
+
+type AuditEventResponse struct {
+	ID         uuid.UUID                     `json:"id"`
+	OccurredAt time.Time                     `json:"occurred_at"`
+	Action     string                        `json:"action" example:"user.deleted"`
+	ActorID    *uuid.UUID                    `json:"actor_id,omitempty"`
+	TargetID   *uuid.UUID                    `json:"target_id,omitempty"`
+	Changes    map[string]domain.AuditChange `json:"changes,omitempty"`
+	Details    map[string]string             `json:"details,omitempty"`
+	IP         string                        `json:"ip"`
+	UserAgent  string                        `json:"user_agent"`
+	RequestID  string                        `json:"request_id"`
+}
+
+type AuditEventsResponse struct {
+	Events []AuditEventResponse `json:"events"`
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+func NewAuditHandler(auditService ports.AuditPort, logger ports.LoggerPort) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+// @Summary Журнал аудита
+// @Description Поиск событий аудита с фильтрами и пагинацией, только для администраторов
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param actor_id query string false "Кто выполнил действие"
+// @Param target_id query string false "Над кем выполнено действие"
+// @Param action query string false "Действие" example:"user.deleted"
+// @Param from query string false "Начало периода, RFC3339"
+// @Param to query string false "Конец периода, RFC3339"
+// @Param limit query int false "Размер страницы, по умолчанию 50, максимум 200"
+// @Param offset query int false "Смещение"
+// @Success 200 {object} AuditEventsResponse "События найдены"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /admin/audit-events [get]
+func (h *AuditHandler) ListEvents(c *gin.Context) {
+	var query AuditEventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid query parameters")
+		return
+	}
+
+	filter := domain.AuditFilter{
+		Action: domain.AuditAction(query.Action),
+		From:   query.From,
+		To:     query.To,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}.Normalized()
+
+	if query.ActorID != "" {
+		actorID, err := uuid.Parse(query.ActorID)
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "Invalid actor_id")
+			return
+		}
+		filter.ActorID = &actorID
+	}
+	if query.TargetID != "" {
+		targetID, err := uuid.Parse(query.TargetID)
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "Invalid target_id")
+			return
+		}
+		filter.TargetID = &targetID
+	}
+
+	events, total, err := h.auditService.ListEvents(c.Request.Context(), filter)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, "Failed to list audit events")
+		return
+	}
+
+	response := AuditEventsResponse{
+		Events: make([]AuditEventResponse, 0, len(events)),
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, AuditEventResponse{
+			ID:         event.ID,
+			OccurredAt: event.OccurredAt,
+			Action:     string(event.Action),
+			ActorID:    event.ActorID,
+			TargetID:   event.TargetID,
+			Changes:    event.Changes,
+			Details:    event.Details,
+			IP:         event.IP,
+			UserAgent:  event.UserAgent,
+			RequestID:  event.RequestID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
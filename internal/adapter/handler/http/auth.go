@@ -2,7 +2,7 @@ package http
 
 import (
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
@@ -24,7 +24,27 @@ type UserInfo struct {
 type AuthHandler struct {
 	authService ports.AuthService
 	logger      ports.LoggerPort
-	metrics     ports.MetricsPort
+}
+
+type IntrospectRequest struct {
+	Token string `form:"token" json:"token" binding:"required"`
+}
+
+// RFC 7662, only active is set for inactive tokens
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Sub       string `json:"sub,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+type LogoutResponse struct {
+	Message string `json:"message"`
 }
 
 type LoginRequest struct {
@@ -35,12 +55,10 @@ type LoginRequest struct {
 func NewAuthHandler(
 	authService ports.AuthService,
 	logger ports.LoggerPort,
-	metrics ports.MetricsPort,
 ) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
 		logger:      logger,
-		metrics:     metrics,
 	}
 }
 
@@ -55,13 +73,6 @@ func NewAuthHandler(
 // @Failure 401 {object} errorResponse "Неверные учетные данные"
 // @Router /login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
-
-	// Getting metrics
-	start := time.Now()
-	defer func() {
-		h.metrics.RecordMetrics(c, start)
-	}()
-
 	var req LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -99,3 +110,78 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// @Summary Проверка токена
+// @Description Интроспекция токена по RFC 7662 для других сервисов, учитывает отзыв токена и состояние аккаунта
+// @Tags auth
+// @Security BasicAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Токен"
+// @Success 200 {object} IntrospectResponse "Результат проверки"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Неверные учетные данные сервиса"
+// @Failure 503 {object} errorResponse "Проверка временно недоступна"
+// @Router /introspect [post]
+func (h *AuthHandler) Introspect(c *gin.Context) {
+	var req IntrospectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	result, err := h.authService.Introspect(c.Request.Context(), req.Token)
+	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "Failed to introspect token", map[string]interface{}{
+			"error": err.Error(),
+		})
+		newErrorResponse(c, http.StatusServiceUnavailable, "Introspection unavailable")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if !result.Active {
+		c.JSON(http.StatusOK, IntrospectResponse{Active: false})
+		return
+	}
+
+	response := IntrospectResponse{
+		Active:    true,
+		Sub:       result.Subject.String(),
+		Role:      string(result.Role),
+		Exp:       result.ExpiresAt.Unix(),
+		Iat:       result.IssuedAt.Unix(),
+		Jti:       result.TokenID.String(),
+		TokenType: "Bearer",
+	}
+	if result.Principal == domain.PrincipalService {
+		response.ClientID = result.Subject.String()
+		response.Scope = strings.Join(result.Scopes, " ")
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Выход из системы
+// @Description Отзыв текущего токена
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} LogoutResponse "Токен отозван"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Router /logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	payload, exists := getAuthPayload(c, authorizationPayloadKey)
+	if !exists {
+		newErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.authService.Revoke(c.Request.Context(), *payload); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, "Logout failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, LogoutResponse{
+		Message: "Token revoked",
+	})
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DeletionHandler struct {
+	deletionService ports.UserDeletionService
+	logger          ports.LoggerPort
+}
+
+type UserDeletionsQuery struct {
+	UserID string `form:"user_id"`
+	Status string `form:"status"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+type UserDeletionResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	Status        string     `json:"status" example:"pending"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	RequestedBy   *uuid.UUID `json:"requested_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
+
+type UserDeletionsResponse struct {
+	Deletions []UserDeletionResponse `json:"deletions"`
+	Total     int                    `json:"total"`
+	Limit     int                    `json:"limit"`
+	Offset    int                    `json:"offset"`
+}
+
+func NewDeletionHandler(deletionService ports.UserDeletionService, logger ports.LoggerPort) *DeletionHandler {
+	return &DeletionHandler{
+		deletionService: deletionService,
+		logger:          logger,
+	}
+}
+
+// @Summary Удаления пользователей
+// @Description Ход удаления аккаунтов: pending, bikes_removed, completed или compensated, если Bike service отказал и аккаунт восстановлен
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query string false "ID пользователя"
+// @Param status query string false "pending, bikes_removed, completed или compensated"
+// @Param limit query int false "Размер страницы, по умолчанию 50, максимум 200"
+// @Param offset query int false "Смещение"
+// @Success 200 {object} UserDeletionsResponse "Удаления"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /admin/user-deletions [get]
+func (h *DeletionHandler) ListDeletions(c *gin.Context) {
+	var query UserDeletionsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid query parameters")
+		return
+	}
+
+	filter := domain.DeletionFilter{
+		Status: domain.DeletionStatus(query.Status),
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}.Normalized()
+	if query.UserID != "" {
+		userID, err := uuid.Parse(query.UserID)
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	deletions, total, err := h.deletionService.ListDeletions(c.Request.Context(), filter)
+	if err != nil {
+		h.handleError(c, err, "Failed to list user deletions")
+		return
+	}
+
+	response := UserDeletionsResponse{
+		Deletions: make([]UserDeletionResponse, 0, len(deletions)),
+		Total:     total,
+		Limit:     filter.Limit,
+		Offset:    filter.Offset,
+	}
+	for i := range deletions {
+		response.Deletions = append(response.Deletions, toUserDeletionResponse(&deletions[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Удаление пользователя
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID удаления"
+// @Success 200 {object} UserDeletionResponse "Удаление"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Удаление не найдено"
+// @Router /admin/user-deletions/{id} [get]
+func (h *DeletionHandler) GetDeletion(c *gin.Context) {
+	deletion, err := h.deletionService.GetDeletion(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to get user deletion")
+		return
+	}
+
+	c.JSON(http.StatusOK, toUserDeletionResponse(deletion))
+}
+
+func (h *DeletionHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidID), errors.Is(err, domain.ErrValidation):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrDeletionNotFound):
+		newErrorResponse(c, http.StatusNotFound, "User deletion not found")
+	default:
+		h.logger.ErrorContext(c.Request.Context(), msg, map[string]interface{}{
+			"error": err.Error(),
+		})
+		newErrorResponse(c, http.StatusInternalServerError, msg)
+	}
+}
+
+func toUserDeletionResponse(deletion *domain.UserDeletion) UserDeletionResponse {
+	return UserDeletionResponse{
+		ID:            deletion.ID,
+		UserID:        deletion.UserID,
+		Status:        string(deletion.Status),
+		Attempts:      deletion.Attempts,
+		NextAttemptAt: deletion.NextAttemptAt,
+		LastError:     deletion.LastError,
+		RequestedBy:   deletion.RequestedBy,
+		CreatedAt:     deletion.CreatedAt,
+		UpdatedAt:     deletion.UpdatedAt,
+		CompletedAt:   deletion.CompletedAt,
+	}
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	statusUp       = "up"
+	statusDown     = "down"
+	statusReady    = "ready"
+	statusNotReady = "not_ready"
+	statusDraining = "shutting_down"
+)
+
+type HealthHandler struct {
+	checkers     []ports.HealthChecker
+	timeout      time.Duration
+	logger       ports.LoggerPort
+	shuttingDown atomic.Bool
+	// Status of the last probe, only changes are logged
+	lastStatus atomic.Value
+}
+
+type LivenessResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+type DependencyStatus struct {
+	Status    string            `json:"status" example:"up"`
+	Critical  bool              `json:"critical" example:"true"`
+	LatencyMs int64             `json:"latency_ms" example:"3"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                      `json:"status" example:"ready"`
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+func NewHealthHandler(
+	checkers []ports.HealthChecker,
+	timeout time.Duration,
+	logger ports.LoggerPort,
+) *HealthHandler {
+	h := &HealthHandler{
+		checkers: checkers,
+		timeout:  timeout,
+		logger:   logger,
+	}
+	h.lastStatus.Store(statusReady)
+	return h
+}
+
+// Marks the instance as not ready so the load balancer drains it before shutdown
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// @Summary Liveness
+// @Description Проверка, что процесс жив
+// @Tags health
+// @Produce json
+// @Success 200 {object} LivenessResponse "Сервис жив"
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, LivenessResponse{
+		Status: "ok",
+	})
+}
+
+// @Summary Readiness
+// @Description Проверка готовности сервиса и его зависимостей
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse "Сервис готов"
+// @Failure 503 {object} ReadinessResponse "Сервис не готов"
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	checks := h.runChecks(c.Request.Context())
+
+	status := statusReady
+	for _, check := range checks {
+		if check.Critical && check.Status != statusUp {
+			status = statusNotReady
+		}
+	}
+	if h.shuttingDown.Load() {
+		status = statusDraining
+	}
+
+	code := http.StatusOK
+	if status != statusReady {
+		code = http.StatusServiceUnavailable
+	}
+
+	if previous := h.lastStatus.Swap(status); previous != status {
+		if status == statusReady {
+			h.logger.Info("Readiness restored", map[string]interface{}{
+				"previous_status": previous,
+			})
+		} else {
+			h.logger.Warn("Readiness check failed", map[string]interface{}{
+				"status": status,
+				"checks": checks,
+			})
+		}
+	}
+
+	c.JSON(code, ReadinessResponse{
+		Status: status,
+		Checks: checks,
+	})
+}
+
+// Runs all checks concurrently, each with its own timeout
+func (h *HealthHandler) runChecks(ctx context.Context) map[string]DependencyStatus {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		checks = make(map[string]DependencyStatus, len(h.checkers))
+	)
+
+	for _, checker := range h.checkers {
+		wg.Add(1)
+		go func(checker ports.HealthChecker) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checker.Check(checkCtx)
+
+			result := DependencyStatus{
+				Status:    statusUp,
+				Critical:  checker.Critical(),
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = statusDown
+				result.Error = err.Error()
+			}
+			if detailer, ok := checker.(ports.HealthDetailer); ok {
+				result.Details = detailer.HealthDetails()
+			}
+
+			mu.Lock()
+			checks[checker.Name()] = result
+			mu.Unlock()
+		}(checker)
+	}
+	wg.Wait()
+
+	return checks
+}
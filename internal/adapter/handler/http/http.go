@@ -1,7 +1,10 @@
 package http
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
@@ -16,7 +19,6 @@ type UserHandler struct {
 	userService  *services.UserService
 	logger       ports.LoggerPort
 	tokenService *JWTTokenService
-	metrics      ports.MetricsPort
 }
 
 type UserRequest struct {
@@ -51,6 +53,35 @@ type GetUserResponse struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+type BatchGetUsersRequest struct {
+	IDs []string `json:"ids" binding:"required" example:"8b8c2d3e-9f4a-4b5c-8d6e-7f8a9b0c1d2e"`
+}
+
+type BatchGetUsersResponse struct {
+	Users []GetUserResponse `json:"users"`
+	// Unknown IDs and IDs the caller may not read
+	Missing []string `json:"missing"`
+}
+
+type SearchUsersQuery struct {
+	Q      string `form:"q" binding:"required"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+type UserSearchHitResponse struct {
+	GetUserResponse
+	Status string  `json:"status" example:"active"`
+	Rank   float64 `json:"rank" example:"0.82"`
+}
+
+type SearchUsersResponse struct {
+	Users  []UserSearchHitResponse `json:"users"`
+	Total  int                     `json:"total"`
+	Limit  int                     `json:"limit"`
+	Offset int                     `json:"offset"`
+}
+
 type UpdateUserResponse struct {
 	ID          uuid.UUID `json:"id"`
 	Name        string    `json:"name"`
@@ -61,20 +92,62 @@ type UpdateUserResponse struct {
 }
 
 type DeleteUserResponse struct {
-	Message string `json:"message"`
+	Message    string    `json:"message"`
+	DeletionID uuid.UUID `json:"deletion_id"`
+	Status     string    `json:"status" example:"completed"`
+}
+
+type BikeResponse struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Brand  string `json:"brand"`
+	Model  string `json:"model"`
+	Type   string `json:"type"`
+	Year   int    `json:"year"`
+}
+
+// Partial is true when the bikes could not be loaded, Bikes is empty then
+type UserWithBikesResponse struct {
+	ID            uuid.UUID      `json:"id"`
+	Name          string         `json:"name"`
+	Email         string         `json:"email"`
+	DateOfBirth   string         `json:"date_of_birth"`
+	Bikes         []BikeResponse `json:"bikes"`
+	Partial       bool           `json:"partial"`
+	PartialReason string         `json:"partial_reason,omitempty"`
+}
+
+type ChangeRoleRequest struct {
+	Role domain.UserRole `json:"role" binding:"required" example:"admin"`
+}
+
+type ImportUserRequest struct {
+	Name        string `json:"name" binding:"required" example:"Иван Иванов"`
+	DateOfBirth string `json:"date_of_birth" binding:"required" example:"1990-01-01"`
+	Email       string `json:"email" binding:"required" example:"ivan@example.com"`
+	// Plain text or a bcrypt hash
+	Password string          `json:"password" binding:"required" example:"password123"`
+	Role     domain.UserRole `json:"role,omitempty" example:"appuser"`
+}
+
+type ImportUsersRequest struct {
+	Users []ImportUserRequest `json:"users" binding:"required,dive"`
+}
+
+type ImportUsersResponse struct {
+	Imported int `json:"imported"`
 }
 
 func NewUserHandler(
 	userService *services.UserService,
 	logger ports.LoggerPort,
 	tokenService *JWTTokenService,
-	metrics ports.MetricsPort,
 ) *UserHandler {
 	return &UserHandler{
 		userService:  userService,
 		logger:       logger,
 		tokenService: tokenService,
-		metrics:      metrics,
 	}
 }
 
@@ -89,11 +162,6 @@ func NewUserHandler(
 // @Failure 409 {object} errorResponse "Email уже существует"
 // @Router /register [post]
 func (h *UserHandler) Register(c *gin.Context) {
-	start := time.Now()
-	defer func() {
-		h.metrics.RecordMetrics(c, start)
-	}()
-
 	var req UserRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -170,11 +238,6 @@ func (h *UserHandler) Register(c *gin.Context) {
 // @Failure 404 {object} errorResponse "Пользователь не найден"
 // @Router /users/{id} [get]
 func (h *UserHandler) GetUser(c *gin.Context) {
-	start := time.Now()
-	defer func() {
-		h.metrics.RecordMetrics(c, start)
-	}()
-
 	userID := c.Param("id")
 
 	payload, exists := getAuthPayload(c, "authorization_payload")
@@ -187,9 +250,9 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	if payload.Role != domain.Admin && payload.UserID.String() != userID {
+	if !payload.CanAccessUser(userID, domain.ScopeUsersRead) {
 		h.logger.Warn("Access denied to user profile", map[string]interface{}{
-			"requester_id": payload.UserID.String(),
+			"requester_id": payload.Subject().String(),
 			"requested_id": userID,
 			"role":         payload.Role,
 		})
@@ -219,6 +282,140 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// @Summary Получить пользователей по ID
+// @Description Пакетное получение пользователей, не больше 100 ID за запрос. Пользователи, которых нет или которые недоступны вызывающему, возвращаются в missing
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body BatchGetUsersRequest true "ID пользователей"
+// @Success 200 {object} BatchGetUsersResponse "Найденные пользователи"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Router /users:batchGet [post]
+func (h *UserHandler) BatchGetUsers(c *gin.Context) {
+	payload, exists := getAuthPayload(c, "authorization_payload")
+	if !exists {
+		newErrorResponse(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req BatchGetUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if len(req.IDs) > services.MaxBatchGetUsers {
+		newErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("At most %d ids are allowed", services.MaxBatchGetUsers))
+		return
+	}
+
+	// Forbidden IDs are reported as missing, so callers cannot probe for accounts
+	allowed := make([]string, 0, len(req.IDs))
+	missing := []string{}
+	for _, id := range req.IDs {
+		if payload.CanAccessUser(id, domain.ScopeUsersRead) {
+			allowed = append(allowed, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	users, notFound, err := h.userService.GetUsers(c.Request.Context(), allowed)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidID), errors.Is(err, domain.ErrValidation):
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.ErrorContext(c.Request.Context(), "Failed to get users", map[string]interface{}{
+				"error": err.Error(),
+				"count": len(req.IDs),
+			})
+			newErrorResponse(c, http.StatusInternalServerError, "Failed to get users")
+		}
+		return
+	}
+
+	response := BatchGetUsersResponse{
+		Users:   make([]GetUserResponse, 0, len(users)),
+		Missing: append(missing, notFound...),
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, GetUserResponse{
+			ID:          user.ID,
+			Name:        user.Name,
+			Email:       user.Email,
+			DateOfBirth: user.DateOfBirth,
+			Role:        string(user.Role),
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Поиск пользователей
+// @Description Поиск по части имени или email с опечатками, запрос на кириллице находит латиницу и наоборот. Лучшие совпадения первыми. Только для администраторов и поддержки
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param q query string true "Строка поиска, от 2 до 100 символов" example:"ivan"
+// @Param limit query int false "Размер страницы, по умолчанию 20, максимум 100"
+// @Param offset query int false "Смещение"
+// @Success 200 {object} SearchUsersResponse "Найденные пользователи"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /users/search [get]
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	var query SearchUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid query parameters")
+		return
+	}
+
+	filter := domain.UserSearchFilter{
+		Query:  query.Q,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}.Normalized()
+
+	hits, total, err := h.userService.SearchUsers(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, domain.ErrValidation) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.ErrorContext(c.Request.Context(), "Failed to search users", map[string]interface{}{
+			"error": err.Error(),
+		})
+		newErrorResponse(c, http.StatusInternalServerError, "Search failed")
+		return
+	}
+
+	response := SearchUsersResponse{
+		Users:  make([]UserSearchHitResponse, 0, len(hits)),
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	for _, hit := range hits {
+		response.Users = append(response.Users, UserSearchHitResponse{
+			GetUserResponse: GetUserResponse{
+				ID:          hit.User.ID,
+				Name:        hit.User.Name,
+				Email:       hit.User.Email,
+				DateOfBirth: hit.User.DateOfBirth,
+				Role:        string(hit.User.Role),
+				CreatedAt:   hit.User.CreatedAt,
+				UpdatedAt:   hit.User.UpdatedAt,
+			},
+			Status: string(hit.User.Status),
+			Rank:   hit.Rank,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // @Summary Обновить пользователя
 // @Description Обновление данных пользователя
 // @Tags users
@@ -231,11 +428,6 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 // @Failure 403 {object} errorResponse "Доступ запрещен"
 // @Router /users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	start := time.Now()
-	defer func() {
-		h.metrics.RecordMetrics(c, start)
-	}()
-
 	userID := c.Param("id")
 
 	payload, exists := getAuthPayload(c, "authorization_payload")
@@ -248,9 +440,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if payload.Role != domain.Admin && payload.UserID.String() != userID {
+	if !payload.CanAccessUser(userID, domain.ScopeUsersWrite) {
 		h.logger.Warn("Access denied to update user", map[string]interface{}{
-			"requester_id": payload.UserID.String(),
+			"requester_id": payload.Subject().String(),
 			"requested_id": userID,
 			"role":         payload.Role,
 		})
@@ -300,6 +492,10 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			newErrorResponse(c, http.StatusConflict, "Email already exists")
 			return
 		}
+		if errors.Is(err, domain.ErrDeletionInProgress) {
+			newErrorResponse(c, http.StatusConflict, "User deletion in progress")
+			return
+		}
 		h.logger.Error("Failed to update user", map[string]interface{}{
 			"error": err.Error(),
 			"id":    userID,
@@ -325,20 +521,20 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 }
 
 // @Summary Удалить пользователя
-// @Description Удаление пользователя
+// @Description Удаление пользователя вместе с его велосипедами в Bike service. Если Bike service недоступен, удаление завершается в фоне, ход виден администраторам в /admin/user-deletions
 // @Tags users
 // @Security BearerAuth
+// @Produce json
 // @Param id path string true "ID юзера" example:"jdk2-fsjmk-daslkdo2-321md-jsnlaljdn"
 // @Success 200 {object} DeleteUserResponse "Пользователь удален"
+// @Success 202 {object} DeleteUserResponse "Удаление продолжится в фоне"
+// @Failure 400 {object} errorResponse "Неверный запрос"
 // @Failure 401 {object} errorResponse "Не авторизован"
 // @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Пользователь не найден"
+// @Failure 409 {object} errorResponse "Удаление уже идет или отменено"
 // @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	start := time.Now()
-	defer func() {
-		h.metrics.RecordMetrics(c, start)
-	}()
-
 	userID := c.Param("id")
 
 	payload, exists := getAuthPayload(c, "authorization_payload")
@@ -351,42 +547,173 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if payload.Role != domain.Admin && payload.UserID.String() != userID {
+	if !payload.CanAccessUser(userID, domain.ScopeUsersWrite) {
 		h.logger.Warn("Access denied to delete user", map[string]interface{}{
-			"requester_id": payload.UserID.String(),
+			"requester_id": payload.Subject().String(),
 			"requested_id": userID,
 		})
 		newErrorResponse(c, http.StatusForbidden, "Access denied")
 		return
 	}
 
-	err := h.userService.DeleteUser(c.Request.Context(), userID)
+	deletion, err := h.userService.DeleteUser(c.Request.Context(), userID)
 	if err != nil {
-		h.logger.Error("Failed to delete user", map[string]interface{}{
+		switch {
+		case errors.Is(err, domain.ErrInvalidID):
+			newErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		case errors.Is(err, domain.ErrUserNotFound):
+			newErrorResponse(c, http.StatusNotFound, "User not found")
+		case errors.Is(err, domain.ErrDeletionInProgress):
+			newErrorResponse(c, http.StatusConflict, "User deletion in progress")
+		default:
+			h.logger.Error("Failed to delete user", map[string]interface{}{
+				"error": err.Error(),
+				"id":    userID,
+			})
+			newErrorResponse(c, http.StatusInternalServerError, "Delete failed")
+		}
+		return
+	}
+
+	switch deletion.Status {
+	case domain.DeletionCompleted:
+		h.logger.Info("User deleted successfully", map[string]interface{}{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusOK, DeleteUserResponse{
+			Message:    "User deleted successfully",
+			DeletionID: deletion.ID,
+			Status:     string(deletion.Status),
+		})
+	case domain.DeletionCompensated:
+		// The bike service refused, the account is kept
+		newErrorResponse(c, http.StatusConflict, "User deletion rolled back, bikes could not be removed")
+	default:
+		c.JSON(http.StatusAccepted, DeleteUserResponse{
+			Message:    "User deletion in progress",
+			DeletionID: deletion.ID,
+			Status:     string(deletion.Status),
+		})
+	}
+}
+
+// @Summary Изменить роль пользователя
+// @Description Назначение роли пользователю, только для администраторов
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "ID юзера" example:"jdk2-fsjmk-daslkdo2-321md-jsnlaljdn"
+// @Param request body ChangeRoleRequest true "Новая роль"
+// @Success 200 {object} UpdateUserResponse "Роль изменена"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Пользователь не найден"
+// @Router /users/{id}/role [put]
+func (h *UserHandler) ChangeRole(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req ChangeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Failed JSON parse in change role", map[string]interface{}{
 			"error": err.Error(),
-			"id":    userID,
 		})
-		newErrorResponse(c, http.StatusInternalServerError, "Delete failed")
+		newErrorResponse(c, http.StatusBadRequest, "Invalid JSON format")
 		return
 	}
 
-	h.logger.Info("User deleted successfully", map[string]interface{}{
-		"user_id": userID,
-	})
+	updatedUser, err := h.userService.ChangeRole(c.Request.Context(), userID, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidID), errors.Is(err, domain.ErrValidation):
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, domain.ErrUserNotFound):
+			newErrorResponse(c, http.StatusNotFound, "User not found")
+		case errors.Is(err, domain.ErrDeletionInProgress):
+			newErrorResponse(c, http.StatusConflict, "User deletion in progress")
+		default:
+			h.logger.Error("Failed to change user role", map[string]interface{}{
+				"error": err.Error(),
+				"id":    userID,
+			})
+			newErrorResponse(c, http.StatusInternalServerError, "Role change failed")
+		}
+		return
+	}
 
-	c.JSON(http.StatusOK, DeleteUserResponse{
-		Message: "User deleted successfully",
+	c.JSON(http.StatusOK, UpdateUserResponse{
+		ID:          updatedUser.ID,
+		Name:        updatedUser.Name,
+		Email:       updatedUser.Email,
+		DateOfBirth: updatedUser.DateOfBirth,
+		Role:        string(updatedUser.Role),
+		UpdatedAt:   updatedUser.UpdatedAt,
 	})
 }
 
-/*
+// @Summary Импорт пользователей
+// @Description Создание до 1000 пользователей за запрос: все или ни одного. Пароль передается открытым текстом или bcrypt-хешем. Только для администраторов
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ImportUsersRequest true "Пользователи"
+// @Success 201 {object} ImportUsersResponse "Пользователи созданы"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 409 {object} errorResponse "Email уже существует"
+// @Router /admin/users/import [post]
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	var req ImportUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	users := make([]domain.User, 0, len(req.Users))
+	for _, user := range req.Users {
+		users = append(users, domain.User{
+			Name:        user.Name,
+			DateOfBirth: user.DateOfBirth,
+			Email:       user.Email,
+			Password:    user.Password,
+			Role:        user.Role,
+		})
+	}
+
+	if err := h.userService.ImportUsers(c.Request.Context(), users); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrValidation):
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, domain.ErrEmailAlreadyExists):
+			newErrorResponse(c, http.StatusConflict, "Email already registered")
+		default:
+			h.logger.ErrorContext(c.Request.Context(), "Failed to import users", map[string]interface{}{
+				"error": err.Error(),
+				"count": len(users),
+			})
+			newErrorResponse(c, http.StatusInternalServerError, "Import failed")
+		}
+		return
+	}
 
-func (h *UserHandler) GetUserWithBikes(c *gin.Context) {
-	start := time.Now()
-	defer func() {
-		h.metrics.RecordMetrics(c, start)
-	}()
+	c.JSON(http.StatusCreated, ImportUsersResponse{Imported: len(users)})
+}
 
+// @Summary Получить пользователя с велосипедами
+// @Description Пользователь и его велосипеды из Bike-сервиса. Если велосипеды получить не удалось, ответ помечается как partial
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID юзера" example:"jdk2-fsjmk-daslkdo2-321md-jsnlaljdn"
+// @Success 200 {object} UserWithBikesResponse "Пользователь найден"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Пользователь не найден"
+// @Router /users/{id}/with-bikes [get]
+func (h *UserHandler) GetUserWithBikes(c *gin.Context) {
 	userID := c.Param("id")
 
 	payload, exists := getAuthPayload(c, "authorization_payload")
@@ -399,9 +726,9 @@ func (h *UserHandler) GetUserWithBikes(c *gin.Context) {
 		return
 	}
 
-	if payload.Role != domain.Admin && payload.UserID.String() != userID {
+	if !payload.CanAccessUser(userID, domain.ScopeUsersRead) {
 		h.logger.Warn("Access denied to user profile", map[string]interface{}{
-			"requester_id": payload.UserID.String(),
+			"requester_id": payload.Subject().String(),
 			"requested_id": userID,
 			"role":         payload.Role,
 		})
@@ -409,8 +736,10 @@ func (h *UserHandler) GetUserWithBikes(c *gin.Context) {
 		return
 	}
 
-	// Получаем юзера
-	user, err := h.userService.GetUser(c.Request.Context(), userID)
+	// Forwarded to the Bike service, which answers for the token owner
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+
+	result, err := h.userService.GetUserWithBikes(c.Request.Context(), userID, token)
 	if err != nil {
 		h.logger.Error("Failed to get user", map[string]interface{}{
 			"error": err.Error(),
@@ -420,40 +749,18 @@ func (h *UserHandler) GetUserWithBikes(c *gin.Context) {
 		return
 	}
 
-	// Вызов Bike-сервиса через HTTP клиент
-	params := bikes.NewGetBikesMyParams()
-	params.Context = c.Request.Context()
-
-	// Подготовка авторизации
-	authHeader := c.GetHeader("Authorization")
-	var authInfo runtime.ClientAuthInfoWriter
-	if authHeader != "" {
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		authInfo = httptransport.BearerToken(token)
-	}
-
-	bikesResp, err := h.bikeClient.Bikes.GetBikesMy(params, authInfo)
-
-	var bikesList []*models.DomainBike
-	if err != nil {
-		h.logger.Warn("Failed to get bikes from Bike service", map[string]interface{}{
-			"error":   err.Error(),
-			"user_id": userID,
-		})
-		bikesList = []*models.DomainBike{}
-	} else {
-		bikesList = bikesResp.Payload.Data
-	}
-
 	response := UserWithBikesResponse{
-		ID:          user.ID.String(),
-		Name:        user.Name,
-		Email:       user.Email,
-		DateOfBirth: user.DateOfBirth,
-		Bikes:       bikesList,
+		ID:            result.User.ID,
+		Name:          result.User.Name,
+		Email:         result.User.Email,
+		DateOfBirth:   result.User.DateOfBirth,
+		Bikes:         make([]BikeResponse, 0, len(result.Bikes)),
+		Partial:       result.Partial,
+		PartialReason: result.PartialReason,
+	}
+	for _, bike := range result.Bikes {
+		response.Bikes = append(response.Bikes, BikeResponse(bike))
 	}
 
-	newSuccessResponse(c, http.StatusOK, "User with bikes found", response)
+	c.JSON(http.StatusOK, response)
 }
-
-*/
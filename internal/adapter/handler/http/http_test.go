@@ -0,0 +1,143 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/bikeservice"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/services"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeUsers serves GetUserByID only
+type fakeUsers struct {
+	ports.UserRepository
+	user domain.User
+}
+
+func (r *fakeUsers) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	if id != r.user.ID {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+// withPayload stands in for AuthMiddleware
+func withPayload(payload *domain.TokenPayload) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(authorizationPayloadKey, payload)
+		c.Request = c.Request.WithContext(requestctx.WithPayload(c.Request.Context(), payload))
+		c.Next()
+	}
+}
+
+func TestGetUserWithBikes_PartialWhenBikeServiceFails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var bikeRequests atomic.Int32
+	bikeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bikeRequests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bikeServer.Close()
+
+	bikes := bikeservice.NewClient(bikeservice.ClientSettings{
+		BaseURL:            bikeServer.URL,
+		BikesPath:          "/bikes",
+		Timeout:            time.Second,
+		MaxRetries:         1,
+		RetryBaseDelay:     time.Millisecond,
+		BreakerThreshold:   5,
+		BreakerOpenTimeout: time.Minute,
+	}, testutil.NopLogger{}, testutil.NopMetrics{})
+
+	user := domain.User{
+		ID:          uuid.New(),
+		Name:        "Иван Иванов",
+		Email:       "ivan@example.com",
+		DateOfBirth: "1990-01-01",
+		Role:        domain.AppUser,
+		Status:      domain.UserActive,
+	}
+	userService := services.NewUserService(&fakeUsers{user: user}, nil, nil, testutil.NopLogger{}, testutil.NopMetrics{}, nil, nil,
+		testutil.NopCache{}, services.CacheSettings{}, bikes)
+	handler := NewUserHandler(userService, testutil.NopLogger{}, nil)
+
+	router := gin.New()
+	router.GET("/users/:id/with-bikes", withPayload(&domain.TokenPayload{
+		ID:        uuid.New(),
+		Principal: domain.PrincipalUser,
+		UserID:    user.ID,
+		Role:      user.Role,
+	}), handler.GetUserWithBikes)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/"+user.ID.String()+"/with-bikes", nil)
+	req.Header.Set("Authorization", "Bearer user-token")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var response UserWithBikesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.ID != user.ID || response.Email != user.Email {
+		t.Errorf("user = %s %s, want %s %s", response.ID, response.Email, user.ID, user.Email)
+	}
+	if !response.Partial {
+		t.Error("response is not marked partial")
+	}
+	if response.PartialReason == "" {
+		t.Error("partial_reason is empty")
+	}
+	if response.Bikes == nil || len(response.Bikes) != 0 {
+		t.Errorf("bikes = %v, want an empty list", response.Bikes)
+	}
+	if got := bikeRequests.Load(); got != 2 {
+		t.Errorf("bike service got %d requests, want 2", got)
+	}
+}
+
+func TestCustomMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/users:method", customMethods(map[string]gin.HandlerFunc{
+		"batchGet": func(c *gin.Context) { c.Status(http.StatusNoContent) },
+	}))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "known method", path: "/users:batchGet", want: http.StatusNoContent},
+		{name: "unknown method", path: "/users:batchDelete", want: http.StatusNotFound},
+		{name: "missing colon", path: "/usersbatchGet", want: http.StatusNotFound},
+		{name: "empty method", path: "/users:", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("POST %s = %d, want %d", tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
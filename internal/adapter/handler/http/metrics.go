@@ -0,0 +1,45 @@
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware records HTTP metrics for every request,
+// labelled by route template instead of the raw path
+func MetricsMiddleware(metrics ports.MetricsPort) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		inFlight := map[string]string{
+			"path":   route,
+			"method": c.Request.Method,
+		}
+		metrics.AddGauge(ports.MetricHTTPInFlight, 1, inFlight)
+		defer metrics.AddGauge(ports.MetricHTTPInFlight, -1, inFlight)
+
+		if c.Request.ContentLength >= 0 {
+			metrics.ObserveValue(ports.MetricHTTPRequestSize, float64(c.Request.ContentLength), inFlight)
+		}
+
+		c.Next()
+
+		labels := map[string]string{
+			"path":   route,
+			"method": c.Request.Method,
+			"status": strconv.Itoa(c.Writer.Status()),
+		}
+		metrics.IncrementCounter(ports.MetricHTTPRequests, labels)
+		metrics.RecordDuration(ports.MetricHTTPRequestDuration, time.Since(start), labels)
+		metrics.ObserveValue(ports.MetricHTTPResponseSize, float64(max(c.Writer.Size(), 0)), labels)
+	}
+}
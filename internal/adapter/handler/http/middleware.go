@@ -1,10 +1,16 @@
 package http
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +21,7 @@ const (
 	authorizationPayloadKey = "authorization_payload"
 )
 
-func AuthMiddleware(token ports.TokenService) gin.HandlerFunc {
+func AuthMiddleware(auth ports.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authorizationHeader := c.GetHeader(authorizationHeaderKey)
 		if authorizationHeader == "" {
@@ -45,7 +51,15 @@ func AuthMiddleware(token ports.TokenService) gin.HandlerFunc {
 		}
 
 		accessToken := fields[1]
-		payload, err := token.VerifyToken(accessToken)
+		payload, err := auth.Authenticate(c.Request.Context(), accessToken)
+		if errors.Is(err, domain.ErrAuthUnavailable) {
+			// The token may be fine, clients must not drop it
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "authentication temporarily unavailable",
+			})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid or expired token",
@@ -55,11 +69,17 @@ func AuthMiddleware(token ports.TokenService) gin.HandlerFunc {
 		}
 
 		c.Set(authorizationPayloadKey, &payload)
+		c.Request = c.Request.WithContext(requestctx.WithPayload(c.Request.Context(), &payload))
 		c.Next()
 	}
 }
 
 func AdminMiddleware() gin.HandlerFunc {
+	return RoleMiddleware(domain.Admin)
+}
+
+// RoleMiddleware admits users with any of roles
+func RoleMiddleware(roles ...domain.UserRole) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		payload, ok := getAuthPayload(ctx, authorizationPayloadKey)
 		if !ok {
@@ -70,9 +90,9 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if payload.Role != domain.Admin {
+		if !slices.Contains(roles, payload.Role) {
 			ctx.JSON(http.StatusForbidden, gin.H{
-				"error": "admin access required",
+				"error": fmt.Sprintf("%s access required", joinRoles(roles)),
 			})
 			ctx.Abort()
 			return
@@ -81,3 +101,42 @@ func AdminMiddleware() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+func joinRoles(roles []domain.UserRole) string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+	return strings.Join(names, " or ")
+}
+
+// ServiceAuthMiddleware admits other services by HTTP Basic client credentials:
+// statically configured ones, or service clients granted the scope
+func ServiceAuthMiddleware(static map[string]string, clients ports.ServiceClientService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, secret, ok := c.Request.BasicAuth()
+		if ok && staticClientValid(static, clientID, secret) {
+			c.Next()
+			return
+		}
+
+		if ok {
+			client, err := clients.AuthenticateClient(c.Request.Context(), clientID, secret)
+			if err == nil && slices.Contains(client.Scopes, scope) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Basic realm="service"`)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "invalid_client",
+		})
+		c.Abort()
+	}
+}
+
+func staticClientValid(static map[string]string, clientID, secret string) bool {
+	expected, known := static[clientID]
+	return known && subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
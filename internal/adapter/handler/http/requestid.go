@@ -0,0 +1,41 @@
+package http
+
+import (
+	"regexp"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeaderKey = "X-Request-ID"
+
+// Incoming IDs are accepted only if they are short and safe to log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware accepts the caller's X-Request-ID or generates one,
+// echoes it back and stores it with the route and client in the request context
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeaderKey)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Header(requestIDHeaderKey, requestID)
+
+		ctx := requestctx.WithRequestID(c.Request.Context(), requestID)
+		ctx = requestctx.WithRoute(ctx, c.FullPath())
+		ctx = requestctx.WithClient(ctx, requestctx.Client{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -1,9 +1,13 @@
 package http
 
 import (
+	"context"
+	"net"
+	"net/http"
 	"strings"
 
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/config"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
 
 	"github.com/gin-contrib/cors"
@@ -13,17 +17,29 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// The config parameter of NewRouter shadows the package
+const envProd = config.EnvProd
+
 type Router struct {
 	*gin.Engine
+	server *http.Server
 }
 
 func NewRouter(
 	config *config.HTTP,
-	tokenService ports.TokenService,
+	introspection *config.Introspection,
+	authService ports.AuthService,
 	userHandler *UserHandler,
 	authHandler *AuthHandler,
+	healthHandler *HealthHandler,
+	auditHandler *AuditHandler,
+	serviceClientHandler *ServiceClientHandler,
+	webhookHandler *WebhookHandler,
+	deletionHandler *DeletionHandler,
+	serviceClients ports.ServiceClientService,
+	metrics ports.MetricsPort,
 ) (*Router, error) {
-	if config.Env == "prod" {
+	if config.Env == envProd {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
@@ -34,7 +50,14 @@ func NewRouter(
 	ginConfig.AllowOrigins = originsList
 
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery(), cors.New(ginConfig))
+	router.Use(
+		gin.Logger(),
+		gin.Recovery(),
+		cors.New(ginConfig),
+		TracingMiddleware(),
+		RequestIDMiddleware(),
+		MetricsMiddleware(metrics),
+	)
 
 	// Swagger
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -42,26 +65,98 @@ func NewRouter(
 	// Metrics
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Health
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+
 	// Routers without auth
 	router.POST("/register", userHandler.Register)
 	router.POST("/login", authHandler.Login)
+	router.POST("/logout", AuthMiddleware(authService), authHandler.Logout)
+
+	// Routers for other services
+	router.POST("/oauth/token", serviceClientHandler.Token)
+	router.POST("/introspect",
+		ServiceAuthMiddleware(introspection.Clients, serviceClients, domain.ScopeTokensIntrospect),
+		authHandler.Introspect,
+	)
 
 	// Routers with auth
+	// Custom methods in the /users:batchGet style, gin only sees a parameter after /users
+	router.POST("/users:method", AuthMiddleware(authService), customMethods(map[string]gin.HandlerFunc{
+		"batchGet": userHandler.BatchGetUsers,
+	}))
+
 	users := router.Group("/users")
-	users.Use(AuthMiddleware(tokenService))
+	users.Use(AuthMiddleware(authService))
 	{
+		users.GET("/search", RoleMiddleware(domain.Admin, domain.Support), userHandler.SearchUsers)
 		users.GET("/:id/with-bikes", userHandler.GetUserWithBikes)
 		users.GET("/:id", userHandler.GetUser)
 		users.PUT("/:id", userHandler.UpdateUser)
 		users.DELETE("/:id", userHandler.DeleteUser)
+		users.PUT("/:id/role", AdminMiddleware(), userHandler.ChangeRole)
+	}
+
+	// Admin only
+	admin := router.Group("/admin")
+	admin.Use(AuthMiddleware(authService), AdminMiddleware())
+	{
+		admin.GET("/audit-events", auditHandler.ListEvents)
+
+		admin.POST("/users/import", userHandler.ImportUsers)
+
+		admin.GET("/service-clients", serviceClientHandler.ListClients)
+		admin.POST("/service-clients", serviceClientHandler.CreateClient)
+		admin.POST("/service-clients/:id/rotate", serviceClientHandler.RotateSecret)
+		admin.DELETE("/service-clients/:id", serviceClientHandler.RevokeClient)
+
+		admin.GET("/webhooks", webhookHandler.ListSubscriptions)
+		admin.POST("/webhooks", webhookHandler.CreateSubscription)
+		admin.DELETE("/webhooks/:id", webhookHandler.DeleteSubscription)
+		admin.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		admin.POST("/webhook-deliveries/:id/redeliver", webhookHandler.Redeliver)
+
+		admin.GET("/user-deletions", deletionHandler.ListDeletions)
+		admin.GET("/user-deletions/:id", deletionHandler.GetDeletion)
 	}
 
 	return &Router{
 		Engine: router,
+		server: &http.Server{
+			Handler: router,
+		},
 	}, nil
 }
 
+// customMethods dispatches on the name after the colon,
+// the route also matches paths like /usersbatchGet which are not found
+func customMethods(methods map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := strings.CutPrefix(c.Param("method"), ":")
+		handler, known := methods[name]
+		if !ok || !known {
+			newErrorResponse(c, http.StatusNotFound, "Not found")
+			return
+		}
+		handler(c)
+	}
+}
+
 // Starts the HTTP server
 func (r *Router) Serve(listenAddr string) error {
-	return r.Run(listenAddr)
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := r.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Stops accepting new connections and waits for active requests
+func (r *Router) Shutdown(ctx context.Context) error {
+	return r.server.Shutdown(ctx)
 }
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ServiceClientHandler struct {
+	clientService ports.ServiceClientService
+	logger        ports.LoggerPort
+}
+
+type CreateServiceClientRequest struct {
+	Name   string   `json:"name" binding:"required" example:"bike-service"`
+	Scopes []string `json:"scopes" binding:"required" example:"users:read"`
+}
+
+type ServiceClientResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Secret is shown only once
+type ServiceClientSecretResponse struct {
+	ServiceClientResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required" example:"client_credentials"`
+	Scope        string `form:"scope" example:"users:read"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	ExpiresIn   int64  `json:"expires_in" example:"900"`
+	Scope       string `json:"scope" example:"users:read"`
+}
+
+func NewServiceClientHandler(clientService ports.ServiceClientService, logger ports.LoggerPort) *ServiceClientHandler {
+	return &ServiceClientHandler{
+		clientService: clientService,
+		logger:        logger,
+	}
+}
+
+// @Summary Токен для сервиса
+// @Description OAuth2 client credentials: выдача токена с правами (scope) другому сервису
+// @Tags auth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "client_credentials"
+// @Param scope formData string false "Права через пробел, по умолчанию все права клиента"
+// @Param client_id formData string false "ID клиента, если не передан через Basic"
+// @Param client_secret formData string false "Секрет клиента, если не передан через Basic"
+// @Success 200 {object} TokenResponse "Токен выдан"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Неверные учетные данные сервиса"
+// @Router /oauth/token [post]
+func (h *ServiceClientHandler) Token(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	if req.GrantType != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, secret = req.ClientID, req.ClientSecret
+	}
+
+	token, scopes, ttl, err := h.clientService.IssueToken(c.Request.Context(), clientID, secret, strings.Fields(req.Scope))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidClient):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		case errors.Is(err, domain.ErrInvalidScope):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+// @Summary Создать сервисного клиента
+// @Description Регистрация сервиса с правами, секрет возвращается только один раз
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateServiceClientRequest true "Данные клиента"
+// @Success 201 {object} ServiceClientSecretResponse "Клиент создан"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /admin/service-clients [post]
+func (h *ServiceClientHandler) CreateClient(c *gin.Context) {
+	var req CreateServiceClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	client, secret, err := h.clientService.CreateClient(c.Request.Context(), req.Name, req.Scopes)
+	if err != nil {
+		h.handleError(c, err, "Failed to create service client")
+		return
+	}
+
+	c.JSON(http.StatusCreated, ServiceClientSecretResponse{
+		ServiceClientResponse: toServiceClientResponse(client),
+		ClientSecret:          secret,
+	})
+}
+
+// @Summary Список сервисных клиентов
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} ServiceClientResponse "Клиенты"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /admin/service-clients [get]
+func (h *ServiceClientHandler) ListClients(c *gin.Context) {
+	clients, err := h.clientService.ListClients(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err, "Failed to list service clients")
+		return
+	}
+
+	response := make([]ServiceClientResponse, 0, len(clients))
+	for i := range clients {
+		response = append(response, toServiceClientResponse(&clients[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Сменить секрет сервисного клиента
+// @Description Новый секрет возвращается только один раз, выданные токены действуют до истечения срока
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID клиента"
+// @Success 200 {object} ServiceClientSecretResponse "Секрет изменен"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Клиент не найден"
+// @Router /admin/service-clients/{id}/rotate [post]
+func (h *ServiceClientHandler) RotateSecret(c *gin.Context) {
+	client, secret, err := h.clientService.RotateSecret(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to rotate service client secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, ServiceClientSecretResponse{
+		ServiceClientResponse: toServiceClientResponse(client),
+		ClientSecret:          secret,
+	})
+}
+
+// @Summary Отозвать сервисного клиента
+// @Description Клиент больше не может получать токены, выданные токены перестают действовать
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "ID клиента"
+// @Success 204 "Клиент отозван"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Клиент не найден"
+// @Router /admin/service-clients/{id} [delete]
+func (h *ServiceClientHandler) RevokeClient(c *gin.Context) {
+	if err := h.clientService.RevokeClient(c.Request.Context(), c.Param("id")); err != nil {
+		h.handleError(c, err, "Failed to revoke service client")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ServiceClientHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidID), errors.Is(err, domain.ErrValidation), errors.Is(err, domain.ErrInvalidScope):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrServiceClientNotFound):
+		newErrorResponse(c, http.StatusNotFound, "Service client not found")
+	default:
+		h.logger.ErrorContext(c.Request.Context(), msg, map[string]interface{}{
+			"error": err.Error(),
+		})
+		newErrorResponse(c, http.StatusInternalServerError, msg)
+	}
+}
+
+func toServiceClientResponse(client *domain.ServiceClient) ServiceClientResponse {
+	return ServiceClientResponse{
+		ID:        client.ID,
+		Name:      client.Name,
+		Scopes:    client.Scopes,
+		CreatedAt: client.CreatedAt,
+		RotatedAt: client.RotatedAt,
+		RevokedAt: client.RevokedAt,
+	}
+}
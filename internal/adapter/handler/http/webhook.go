@@ -0,0 +1,260 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type WebhookHandler struct {
+	webhookService ports.WebhookService
+	logger         ports.LoggerPort
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required" example:"https://partner.example/webhooks/webike"`
+	Events []string `json:"events" binding:"required" example:"user.registered,user.deleted"`
+}
+
+type WebhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Secret is shown only once
+type WebhookSecretResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveriesQuery struct {
+	Status string `form:"status"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	SubscriptionID uuid.UUID  `json:"subscription_id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type" example:"user.registered"`
+	Status         string     `json:"status" example:"pending"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int                       `json:"total"`
+	Limit      int                       `json:"limit"`
+	Offset     int                       `json:"offset"`
+}
+
+func NewWebhookHandler(webhookService ports.WebhookService, logger ports.LoggerPort) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		logger:         logger,
+	}
+}
+
+// @Summary Создать вебхук
+// @Description Подписка партнера на события пользователей. Запросы подписываются HMAC-SHA256: заголовок X-Webike-Signature содержит sha256=hex(HMAC(secret, X-Webike-Timestamp + "." + тело)). Секрет возвращается только один раз
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Адрес и события"
+// @Success 201 {object} WebhookSecretResponse "Вебхук создан"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	events := make([]domain.EventType, 0, len(req.Events))
+	for _, event := range req.Events {
+		events = append(events, domain.EventType(event))
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(c.Request.Context(), req.URL, events)
+	if err != nil {
+		h.handleError(c, err, "Failed to create webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookSecretResponse{
+		WebhookResponse: toWebhookResponse(subscription),
+		Secret:          subscription.Secret,
+	})
+}
+
+// @Summary Список вебхуков
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} WebhookResponse "Вебхуки"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.webhookService.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err, "Failed to list webhooks")
+		return
+	}
+
+	response := make([]WebhookResponse, 0, len(subscriptions))
+	for i := range subscriptions {
+		response = append(response, toWebhookResponse(&subscriptions[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Удалить вебхук
+// @Description Удаляет подписку вместе с журналом доставок
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "ID вебхука"
+// @Success 204 "Вебхук удален"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Вебхук не найден"
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	if err := h.webhookService.DeleteSubscription(c.Request.Context(), c.Param("id")); err != nil {
+		h.handleError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Журнал доставок вебхука
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID вебхука"
+// @Param status query string false "pending, succeeded или dead"
+// @Param limit query int false "Размер страницы, по умолчанию 50, максимум 200"
+// @Param offset query int false "Смещение"
+// @Success 200 {object} WebhookDeliveriesResponse "Доставки"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Вебхук не найден"
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var query WebhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "Invalid query parameters")
+		return
+	}
+
+	filter := domain.DeliveryFilter{
+		Status: domain.DeliveryStatus(query.Status),
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}.Normalized()
+
+	deliveries, total, err := h.webhookService.ListDeliveries(c.Request.Context(), c.Param("id"), filter)
+	if err != nil {
+		h.handleError(c, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	response := WebhookDeliveriesResponse{
+		Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries)),
+		Total:      total,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	}
+	for i := range deliveries {
+		response.Deliveries = append(response.Deliveries, toWebhookDeliveryResponse(&deliveries[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Повторить доставку
+// @Description Ставит доставку в очередь заново с полным числом попыток, в том числе после dead
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID доставки"
+// @Success 202 {object} WebhookDeliveryResponse "Доставка поставлена в очередь"
+// @Failure 400 {object} errorResponse "Неверный запрос"
+// @Failure 401 {object} errorResponse "Не авторизован"
+// @Failure 403 {object} errorResponse "Доступ запрещен"
+// @Failure 404 {object} errorResponse "Доставка не найдена"
+// @Router /admin/webhook-deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.webhookService.Redeliver(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to redeliver webhook")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, toWebhookDeliveryResponse(delivery))
+}
+
+func (h *WebhookHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidID), errors.Is(err, domain.ErrValidation):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		newErrorResponse(c, http.StatusNotFound, "Webhook not found")
+	case errors.Is(err, domain.ErrDeliveryNotFound):
+		newErrorResponse(c, http.StatusNotFound, "Webhook delivery not found")
+	default:
+		h.logger.ErrorContext(c.Request.Context(), msg, map[string]interface{}{
+			"error": err.Error(),
+		})
+		newErrorResponse(c, http.StatusInternalServerError, msg)
+	}
+}
+
+func toWebhookResponse(subscription *domain.WebhookSubscription) WebhookResponse {
+	events := make([]string, 0, len(subscription.Events))
+	for _, event := range subscription.Events {
+		events = append(events, string(event))
+	}
+
+	return WebhookResponse{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    events,
+		CreatedAt: subscription.CreatedAt,
+	}
+}
+
+func toWebhookDeliveryResponse(delivery *domain.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.Event.ID,
+		EventType:      string(delivery.Event.Type),
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextHandler adds request metadata found in the context:
+// request ID, route, caller's user or client ID and the active trace,
+// so Grafana can jump from a Loki line to the trace
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := requestctx.RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if route := requestctx.Route(ctx); route != "" {
+		record.AddAttrs(slog.String("route", route))
+	}
+	if payload, ok := requestctx.Payload(ctx); ok {
+		if payload.IsService() {
+			record.AddAttrs(slog.String("client_id", payload.ClientID.String()))
+		} else {
+			record.AddAttrs(slog.String("user_id", payload.UserID.String()))
+		}
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
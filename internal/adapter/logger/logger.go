@@ -2,41 +2,55 @@ package logger
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/config"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
 )
 
 const (
 	envLocal = "local"
 	envDev   = "dev"
-	envProd  = "prod"
+	envProd  = config.EnvProd
 )
 
 type LoggerAdapter struct {
 	logger *slog.Logger
 }
 
-func NewLoggerAdapter(env string) ports.LoggerPort {
-	var log *slog.Logger
+// Production always redacts PII, in other envs only when enabled in the rules
+func NewLoggerAdapter(env string, redaction RedactionRules) (ports.LoggerPort, error) {
+	handler, err := newHandler(env, os.Stdout, redaction)
+	if err != nil {
+		return nil, err
+	}
+	return &LoggerAdapter{
+		logger: slog.New(contextHandler{handler}),
+	}, nil
+}
+
+func newHandler(env string, out io.Writer, redaction RedactionRules) (slog.Handler, error) {
+	if env == envProd {
+		redaction.Enabled = true
+	}
+
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if redaction.Enabled {
+		redactor, err := newRedactor(withDefaultRules(redaction))
+		if err != nil {
+			return nil, err
+		}
+		options.ReplaceAttr = redactor.replaceAttr
+	}
 
 	switch env {
-	case envLocal, envDev:
-		log = slog.New(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-		)
 	case envProd:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
+		options.Level = slog.LevelInfo
+		return slog.NewJSONHandler(out, options), nil
 	default:
-		log = slog.New(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-		)
-	}
-
-	return &LoggerAdapter{
-		logger: log,
+		return slog.NewTextHandler(out, options), nil
 	}
 }
 
@@ -72,6 +86,38 @@ func (l *LoggerAdapter) Warn(msg string, fields map[string]interface{}) {
 	l.logger.Warn(msg, slog.Any("fields", fields))
 }
 
+func (l *LoggerAdapter) InfoContext(ctx context.Context, msg string, fields map[string]interface{}) {
+	if fields == nil {
+		l.logger.InfoContext(ctx, msg)
+		return
+	}
+	l.logger.InfoContext(ctx, msg, slog.Any("fields", fields))
+}
+
+func (l *LoggerAdapter) ErrorContext(ctx context.Context, msg string, fields map[string]interface{}) {
+	if fields == nil {
+		l.logger.ErrorContext(ctx, msg)
+		return
+	}
+	l.logger.ErrorContext(ctx, msg, slog.Any("fields", fields))
+}
+
+func (l *LoggerAdapter) DebugContext(ctx context.Context, msg string, fields map[string]interface{}) {
+	if fields == nil {
+		l.logger.DebugContext(ctx, msg)
+		return
+	}
+	l.logger.DebugContext(ctx, msg, slog.Any("fields", fields))
+}
+
+func (l *LoggerAdapter) WarnContext(ctx context.Context, msg string, fields map[string]interface{}) {
+	if fields == nil {
+		l.logger.WarnContext(ctx, msg)
+		return
+	}
+	l.logger.WarnContext(ctx, msg, slog.Any("fields", fields))
+}
+
 func (l *LoggerAdapter) InfoGRPC(ctx context.Context, msg string, fields any) {
 	if fields == nil {
 		l.logger.InfoContext(ctx, msg)
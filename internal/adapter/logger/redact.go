@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+type RedactAction int
+
+const (
+	// Keeps the shape of the value, e.g. i***@example.com
+	RedactMask RedactAction = iota
+	// Replaces the value with a keyed hash, equal values still correlate
+	RedactHash
+	// Removes the key from the output
+	RedactDrop
+)
+
+type RedactionRules struct {
+	Enabled bool
+	// Secret for hashing, required so hashes match across replicas and restarts
+	HashKey    string
+	MaskFields []string
+	HashFields []string
+	DropFields []string
+}
+
+// Rules applied whenever redaction is on, configured fields are added on top
+var DefaultRedactionRules = RedactionRules{
+	MaskFields: []string{"email"},
+	HashFields: []string{"id", "user_id", "requester_id", "requested_id", "requested_user_id", "target_id", "actor_id"},
+	DropFields: []string{"password", "token", "access_token", "refresh_token", "authorization", "secret", "api_key"},
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// redactor rewrites log attributes by key, walking nested maps, slices and structs.
+// Emails are masked inside any string, whatever key they are under
+type redactor struct {
+	actions map[string]RedactAction
+	hashKey []byte
+}
+
+// ErrNoHashKey is returned when redaction is on without RedactionRules.HashKey
+var ErrNoHashKey = errors.New("log redaction requires a hash key")
+
+func newRedactor(rules RedactionRules) (*redactor, error) {
+	if rules.HashKey == "" {
+		return nil, ErrNoHashKey
+	}
+
+	r := &redactor{
+		actions: make(map[string]RedactAction),
+		hashKey: []byte(rules.HashKey),
+	}
+
+	for _, field := range rules.MaskFields {
+		r.actions[strings.ToLower(field)] = RedactMask
+	}
+	for _, field := range rules.HashFields {
+		r.actions[strings.ToLower(field)] = RedactHash
+	}
+	for _, field := range rules.DropFields {
+		r.actions[strings.ToLower(field)] = RedactDrop
+	}
+	return r, nil
+}
+
+func withDefaultRules(rules RedactionRules) RedactionRules {
+	return RedactionRules{
+		Enabled:    rules.Enabled,
+		HashKey:    rules.HashKey,
+		MaskFields: append(append([]string{}, DefaultRedactionRules.MaskFields...), rules.MaskFields...),
+		HashFields: append(append([]string{}, DefaultRedactionRules.HashFields...), rules.HashFields...),
+		DropFields: append(append([]string{}, DefaultRedactionRules.DropFields...), rules.DropFields...),
+	}
+}
+
+// replaceAttr is used as slog.HandlerOptions.ReplaceAttr
+func (r *redactor) replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch attr.Key {
+		case slog.TimeKey, slog.LevelKey, slog.MessageKey, slog.SourceKey:
+			return attr
+		}
+	}
+
+	value, keep := r.redact(attr.Key, attr.Value.Resolve().Any())
+	if !keep {
+		return slog.Attr{}
+	}
+	return slog.Any(attr.Key, value)
+}
+
+func (r *redactor) redact(key string, value interface{}) (interface{}, bool) {
+	if action, ok := r.actions[strings.ToLower(key)]; ok {
+		switch action {
+		case RedactDrop:
+			return nil, false
+		case RedactHash:
+			return r.hash(value), true
+		case RedactMask:
+			return maskValue(value), true
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if item, keep := r.redact(k, item); keep {
+				redacted[k] = item
+			}
+		}
+		return redacted, true
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for k, item := range v {
+			if item, keep := r.redact(k, item); keep {
+				redacted[k] = fmt.Sprint(item)
+			}
+		}
+		return redacted, true
+	case []interface{}:
+		redacted := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			item, _ := r.redact("", item)
+			redacted = append(redacted, item)
+		}
+		return redacted, true
+	case []string:
+		redacted := make([]string, 0, len(v))
+		for _, item := range v {
+			redacted = append(redacted, maskEmails(item))
+		}
+		return redacted, true
+	case string:
+		return maskEmails(v), true
+	case error:
+		return maskEmails(v.Error()), true
+	case slog.LogValuer:
+		return r.redact(key, v.LogValue().Resolve().Any())
+	case []slog.Attr:
+		// A group value, redacted like a map
+		group := make(map[string]interface{}, len(v))
+		for _, attr := range v {
+			group[attr.Key] = attr.Value.Resolve().Any()
+		}
+		return r.redact(key, group)
+	default:
+		return r.redactComposite(key, value)
+	}
+}
+
+// redactComposite walks structs, pointers and other maps and slices through their JSON form,
+// so fields are redacted by their JSON names. Values JSON cannot encode are dropped
+func (r *redactor) redactComposite(key string, value interface{}) (interface{}, bool) {
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, false
+	default:
+		return value, true
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, false
+	}
+	return r.redact(key, decoded)
+}
+
+func (r *redactor) hash(value interface{}) string {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(fmt.Sprint(value)))
+	return "h:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+func maskValue(value interface{}) string {
+	str := fmt.Sprint(value)
+	if emailPattern.MatchString(str) {
+		return maskEmails(str)
+	}
+	return "***"
+}
+
+func maskEmails(str string) string {
+	return emailPattern.ReplaceAllStringFunc(str, maskEmail)
+}
+
+// ivan@example.com -> i***@example.com
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type testProfile struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Nickname string `json:"nickname"`
+}
+
+type testSecret string
+
+func (testSecret) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("token", "tok-123"),
+		slog.String("email", "valuer@example.com"),
+	)
+}
+
+// newTestLogger logs like production into buf
+func newTestLogger(t *testing.T, buf *bytes.Buffer) *LoggerAdapter {
+	t.Helper()
+
+	handler, err := newHandler(envProd, buf, RedactionRules{HashKey: "test"})
+	if err != nil {
+		t.Fatalf("newHandler: %v", err)
+	}
+	return &LoggerAdapter{
+		logger: slog.New(contextHandler{handler}),
+	}
+}
+
+// fields decodes the fields of the single entry in buf
+func fields(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry %q: %v", buf.String(), err)
+	}
+	fields, ok := entry["fields"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no fields in log entry %q", buf.String())
+	}
+	return fields
+}
+
+func assertNoPII(t *testing.T, buf *bytes.Buffer) {
+	t.Helper()
+
+	for _, secret := range []string{"ivan@example.com", "nested@example.com", "item@example.com", "valuer@example.com", "hunter2", "tok-123", "11111111-1111-1111-1111-111111111111"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("log entry leaks %q: %s", secret, buf.String())
+		}
+	}
+}
+
+func TestRedactTopLevelFields(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf).Info("User registered", map[string]interface{}{
+		"id":       "11111111-1111-1111-1111-111111111111",
+		"email":    "ivan@example.com",
+		"password": "hunter2",
+		"message":  "welcome mail sent to ivan@example.com",
+		"count":    3,
+	})
+
+	got := fields(t, &buf)
+	assertNoPII(t, &buf)
+
+	if got["email"] != "i***@example.com" {
+		t.Errorf("email = %v, want i***@example.com", got["email"])
+	}
+	if id, _ := got["id"].(string); !strings.HasPrefix(id, "h:") {
+		t.Errorf("id = %v, want a hash", got["id"])
+	}
+	if _, ok := got["password"]; ok {
+		t.Error("password is not dropped")
+	}
+	if got["message"] != "welcome mail sent to i***@example.com" {
+		t.Errorf("message = %v", got["message"])
+	}
+	if got["count"] != float64(3) {
+		t.Errorf("count = %v, want 3", got["count"])
+	}
+}
+
+func TestRedactNestedMap(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf).ErrorContext(context.Background(), "Failed to update user", map[string]interface{}{
+		"request": map[string]interface{}{
+			"email": "nested@example.com",
+			"auth": map[string]string{
+				"token": "tok-123",
+				"scope": "users:read",
+			},
+		},
+	})
+
+	request, _ := fields(t, &buf)["request"].(map[string]interface{})
+	assertNoPII(t, &buf)
+
+	if request["email"] != "n***@example.com" {
+		t.Errorf("request.email = %v, want n***@example.com", request["email"])
+	}
+	auth, _ := request["auth"].(map[string]interface{})
+	if _, ok := auth["token"]; ok {
+		t.Error("request.auth.token is not dropped")
+	}
+	if auth["scope"] != "users:read" {
+		t.Errorf("request.auth.scope = %v, want users:read", auth["scope"])
+	}
+}
+
+func TestRedactSlice(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf).Warn("Import rejected", map[string]interface{}{
+		"rows": []interface{}{
+			map[string]interface{}{"email": "item@example.com", "password": "hunter2"},
+			"item@example.com",
+			42,
+		},
+	})
+
+	rows, _ := fields(t, &buf)["rows"].([]interface{})
+	assertNoPII(t, &buf)
+
+	if len(rows) != 3 {
+		t.Fatalf("rows = %v, want 3 items", rows)
+	}
+	row, _ := rows[0].(map[string]interface{})
+	if row["email"] != "i***@example.com" {
+		t.Errorf("rows[0].email = %v", row["email"])
+	}
+	if _, ok := row["password"]; ok {
+		t.Error("rows[0].password is not dropped")
+	}
+	if rows[1] != "i***@example.com" {
+		t.Errorf("rows[1] = %v", rows[1])
+	}
+}
+
+func TestRedactStructs(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf).InfoGRPC(context.Background(), "Request", &testProfile{
+		ID:       "11111111-1111-1111-1111-111111111111",
+		Email:    "ivan@example.com",
+		Password: "hunter2",
+		Nickname: "ivan",
+	})
+
+	got := fields(t, &buf)
+	assertNoPII(t, &buf)
+
+	if got["email"] != "i***@example.com" {
+		t.Errorf("email = %v, want i***@example.com", got["email"])
+	}
+	if _, ok := got["password"]; ok {
+		t.Error("password is not dropped")
+	}
+	if got["nickname"] != "ivan" {
+		t.Errorf("nickname = %v, want ivan", got["nickname"])
+	}
+
+	buf.Reset()
+	newTestLogger(t, &buf).Info("Nested", map[string]interface{}{
+		"profiles": []testProfile{{Email: "nested@example.com", Password: "hunter2"}},
+		"secret":   testSecret("raw"),
+		"session":  testSecret("raw"),
+	})
+	assertNoPII(t, &buf)
+
+	session, _ := fields(t, &buf)["session"].(map[string]interface{})
+	if session["email"] != "v***@example.com" {
+		t.Errorf("session.email = %v, want v***@example.com", session["email"])
+	}
+}
+
+func TestRedactUnencodableValueIsDropped(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf).Info("Odd", map[string]interface{}{
+		"callback": map[string]interface{}{"fn": func() {}},
+		"note":     "kept",
+	})
+
+	got := fields(t, &buf)
+	if _, ok := got["callback"].(map[string]interface{})["fn"]; ok {
+		t.Errorf("callback.fn = %v, want it dropped", got["callback"])
+	}
+	if got["note"] != "kept" {
+		t.Errorf("note = %v, want kept", got["note"])
+	}
+}
+
+func TestNewHandler_RequiresHashKeyWhenRedacting(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		rules   RedactionRules
+		wantErr bool
+	}{
+		{name: "production", env: envProd, wantErr: true},
+		{name: "enabled in dev", env: envDev, rules: RedactionRules{Enabled: true}, wantErr: true},
+		{name: "off in dev", env: envDev},
+		{name: "production with key", env: envProd, rules: RedactionRules{HashKey: "key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newHandler(tt.env, &bytes.Buffer{}, tt.rules)
+			if tt.wantErr && !errors.Is(err, ErrNoHashKey) {
+				t.Errorf("err = %v, want ErrNoHashKey", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("newHandler: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+type HealthChecker struct {
+	db *sql.DB
+}
+
+func NewHealthChecker(db *sql.DB) ports.HealthChecker {
+	return &HealthChecker{
+		db: db,
+	}
+}
+
+func (h *HealthChecker) Name() string {
+	return "postgres"
+}
+
+func (h *HealthChecker) Critical() bool {
+	return true
+}
+
+func (h *HealthChecker) Check(ctx context.Context) error {
+	return h.db.PingContext(ctx)
+}
+
+var _ ports.HealthChecker = (*HealthChecker)(nil)
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- No foreign keys: events must outlive the users they mention
+CREATE TABLE IF NOT EXISTS audit_events (
+ id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+ occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ action VARCHAR(64) NOT NULL,
+ actor_id UUID,
+ target_id UUID,
+ changes JSONB NOT NULL DEFAULT '{}',
+ details JSONB NOT NULL DEFAULT '{}',
+ ip VARCHAR(64) NOT NULL DEFAULT '',
+ user_agent TEXT NOT NULL DEFAULT '',
+ request_id VARCHAR(128) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON audit_events (occurred_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_action_idx ON audit_events (action, occurred_at DESC);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+ RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Written in the same transaction as the user change, published by the relay
+CREATE TABLE IF NOT EXISTS outbox_events (
+ id BIGSERIAL PRIMARY KEY,
+ event_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+ aggregate_id UUID NOT NULL,
+ event_type VARCHAR(64) NOT NULL,
+ payload JSONB NOT NULL,
+ created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ published_at TIMESTAMPTZ,
+ attempts INT NOT NULL DEFAULT 0,
+ last_error TEXT,
+ -- Failed events back off, and are dead-lettered after too many attempts
+ next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ dead_at TIMESTAMPTZ
+);
+
+-- The relay looks up the oldest pending event of each user
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (aggregate_id, id)
+ WHERE published_at IS NULL AND dead_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_events_published_idx ON outbox_events (published_at) WHERE published_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS outbox_events_dead_idx ON outbox_events (dead_at) WHERE dead_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox_events;
+-- +goose StatementEnd
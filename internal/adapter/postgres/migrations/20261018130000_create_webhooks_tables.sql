@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+ id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+ url TEXT NOT NULL,
+ events TEXT[] NOT NULL,
+ secret VARCHAR(128) NOT NULL,
+ created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The queue and the delivery log at once
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+ id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+ subscription_id UUID NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+ event_id UUID NOT NULL,
+ event_type VARCHAR(64) NOT NULL,
+ aggregate_id UUID NOT NULL,
+ occurred_at TIMESTAMPTZ NOT NULL,
+ -- Partners get the user id only, never the PII of the user event
+ payload JSONB NOT NULL CHECK (payload - 'user_id' = '{}'::jsonb),
+ status VARCHAR(16) NOT NULL DEFAULT 'pending',
+ attempts INT NOT NULL DEFAULT 0,
+ next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ last_status_code INT NOT NULL DEFAULT 0,
+ last_error TEXT NOT NULL DEFAULT '',
+ created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ delivered_at TIMESTAMPTZ,
+ UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+-- +goose StatementEnd
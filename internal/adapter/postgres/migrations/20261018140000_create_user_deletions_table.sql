@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'active';
+
+-- No foreign key: the saga outlives the user it deletes
+CREATE TABLE IF NOT EXISTS user_deletions (
+ id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+ user_id UUID NOT NULL,
+ status VARCHAR(32) NOT NULL DEFAULT 'pending',
+ attempts INT NOT NULL DEFAULT 0,
+ next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ last_error TEXT NOT NULL DEFAULT '',
+ requested_by UUID,
+ created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ completed_at TIMESTAMPTZ
+);
+
+-- One running deletion per user
+CREATE UNIQUE INDEX IF NOT EXISTS user_deletions_running_idx ON user_deletions (user_id)
+ WHERE status IN ('pending', 'bikes_removed');
+CREATE INDEX IF NOT EXISTS user_deletions_due_idx ON user_deletions (next_attempt_at)
+ WHERE status IN ('pending', 'bikes_removed');
+CREATE INDEX IF NOT EXISTS user_deletions_created_at_idx ON user_deletions (created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_deletions;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
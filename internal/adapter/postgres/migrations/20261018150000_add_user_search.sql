@@ -0,0 +1,22 @@
+-- +goose NO TRANSACTION
+-- ALTER TYPE ... ADD VALUE and CREATE INDEX CONCURRENTLY cannot run in a transaction
+
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TYPE user_role_enum ADD VALUE IF NOT EXISTS 'support';
+
+-- 'simple' keeps names as typed, language stemming would mangle them
+ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector
+ GENERATED ALWAYS AS (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(email, ''))) STORED;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_search_vector_idx ON users USING GIN (search_vector);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name_trgm_idx ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_email_trgm_idx ON users USING GIN (email gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS users_email_trgm_idx;
+DROP INDEX CONCURRENTLY IF EXISTS users_name_trgm_idx;
+DROP INDEX CONCURRENTLY IF EXISTS users_search_vector_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+-- Enum values cannot be dropped, 'support' stays
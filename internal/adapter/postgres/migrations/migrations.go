@@ -0,0 +1,10 @@
+// Package migrations holds the SQL migrations, embedded into the binary
+package migrations
+
+import "embed"
+
+// Dir is where new migrations are created, relative to the repository root
+const Dir = "internal/adapter/postgres/migrations"
+
+//go:embed *.sql
+var FS embed.FS
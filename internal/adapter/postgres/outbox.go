@@ -0,0 +1,294 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// Key of the advisory lock held by the active relay
+const outboxLockKey = 7_403_112_044
+
+type OutboxSettings struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Published rows are kept this long, then deleted
+	Retention       time.Duration
+	CleanupInterval time.Duration
+	// Failed events are dead-lettered after this many attempts
+	MaxAttempts int
+	// Delay after the first failed attempt, doubled for every next one
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// OutboxRelay publishes events written to outbox_events by the repositories.
+// Only the replica holding the advisory lock relays, and it only takes the oldest
+// pending event of each user, so events of one user are delivered in order while
+// a failing user backs off without holding up the others. An event still failing
+// after MaxAttempts is dead-lettered and the events after it go on.
+// Rows are marked published after the publisher accepted them: a crash in
+// between publishes the event again
+type OutboxRelay struct {
+	db        *sql.DB
+	publisher ports.EventPublisher
+	logger    ports.LoggerPort
+	metrics   ports.MetricsPort
+	settings  OutboxSettings
+}
+
+func NewOutboxRelay(db *sql.DB, publisher ports.EventPublisher, logger ports.LoggerPort, metrics ports.MetricsPort, settings OutboxSettings) *OutboxRelay {
+	return &OutboxRelay{
+		db:        db,
+		publisher: publisher,
+		logger:    logger,
+		metrics:   metrics,
+		settings:  settings,
+	}
+}
+
+// Run relays events until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.settings.PollInterval)
+	defer ticker.Stop()
+
+	var conn *sql.Conn
+	defer func() {
+		if conn != nil {
+			r.unlock(conn)
+		}
+	}()
+
+	var lastCleanup time.Time
+	for {
+		// The lock lives as long as its connection
+		if conn != nil && conn.PingContext(ctx) != nil {
+			conn.Close()
+			conn = nil
+		}
+		if conn == nil {
+			conn = r.tryLock(ctx)
+		}
+
+		if conn != nil {
+			if err := r.relay(ctx, conn); err != nil && ctx.Err() == nil {
+				r.logger.ErrorContext(ctx, "Failed to relay outbox events", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+
+			if time.Since(lastCleanup) >= r.settings.CleanupInterval {
+				lastCleanup = time.Now()
+				r.cleanup(ctx, conn)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *OutboxRelay) tryLock(ctx context.Context) *sql.Conn {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, outboxLockKey).Scan(&locked); err != nil || !locked {
+		conn.Close()
+		return nil
+	}
+
+	r.logger.Info("Outbox relay acquired the lock", nil)
+	return conn
+}
+
+func (r *OutboxRelay) unlock(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, _ = conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, outboxLockKey)
+	conn.Close()
+}
+
+// relay publishes due events batch by batch while it makes progress,
+// failed ones wait for their next attempt
+func (r *OutboxRelay) relay(ctx context.Context, conn *sql.Conn) error {
+	for {
+		events, err := r.pending(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		published := 0
+		for _, event := range events {
+			if err := r.publisher.Publish(ctx, event.Event); err != nil {
+				if err := r.fail(ctx, conn, event, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := r.markPublished(ctx, conn, event.rowID); err != nil {
+				return err
+			}
+			published++
+			r.metrics.IncrementCounter(ports.MetricOutboxPublished, map[string]string{
+				"type":    string(event.Type),
+				"outcome": ports.OutcomeSuccess,
+			})
+		}
+
+		r.updatePending(ctx, conn)
+		// Publishing an event makes the next one of the same user due
+		if published == 0 {
+			return nil
+		}
+	}
+}
+
+type outboxEvent struct {
+	domain.Event
+	rowID    int64
+	attempts int
+}
+
+// pending returns the oldest pending event of each user, if it is due
+func (r *OutboxRelay) pending(ctx context.Context, conn *sql.Conn) ([]outboxEvent, error) {
+	query := `SELECT e.id, e.event_id, e.aggregate_id, e.event_type, e.payload, e.created_at, e.attempts
+        FROM outbox_events e
+        WHERE e.published_at IS NULL AND e.dead_at IS NULL
+        AND e.next_attempt_at <= CURRENT_TIMESTAMP
+        AND NOT EXISTS (
+            SELECT 1 FROM outbox_events o
+            WHERE o.aggregate_id = e.aggregate_id
+            AND o.published_at IS NULL AND o.dead_at IS NULL
+            AND o.id < e.id
+        )
+        ORDER BY e.id
+        LIMIT $1`
+
+	rows, err := conn.QueryContext(ctx, query, r.settings.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("select pending events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []outboxEvent
+	for rows.Next() {
+		var event outboxEvent
+		var eventType string
+		var payload []byte
+		if err := rows.Scan(
+			&event.rowID,
+			&event.ID,
+			&event.AggregateID,
+			&eventType,
+			&payload,
+			&event.OccurredAt,
+			&event.attempts,
+		); err != nil {
+			return nil, fmt.Errorf("scan pending event: %w", err)
+		}
+		event.Type = domain.EventType(eventType)
+		event.Payload = payload
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (r *OutboxRelay) markPublished(ctx context.Context, conn *sql.Conn, id int64) error {
+	query := `UPDATE outbox_events
+        SET published_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = NULL
+        WHERE id = $1`
+
+	if _, err := conn.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("mark event published: %w", err)
+	}
+	return nil
+}
+
+// fail schedules the next attempt of event, or dead-letters it after MaxAttempts
+func (r *OutboxRelay) fail(ctx context.Context, conn *sql.Conn, event outboxEvent, cause error) error {
+	attempts := event.attempts + 1
+	fields := map[string]interface{}{
+		"event_id": event.ID.String(),
+		"type":     string(event.Type),
+		"attempts": attempts,
+		"error":    cause.Error(),
+	}
+
+	if attempts >= r.settings.MaxAttempts {
+		query := `UPDATE outbox_events
+            SET attempts = $2, last_error = $3, dead_at = CURRENT_TIMESTAMP
+            WHERE id = $1`
+		if _, err := conn.ExecContext(ctx, query, event.rowID, attempts, cause.Error()); err != nil {
+			return fmt.Errorf("dead-letter event: %w", err)
+		}
+		r.metrics.IncrementCounter(ports.MetricOutboxPublished, map[string]string{
+			"type":    string(event.Type),
+			"outcome": ports.OutcomeDead,
+		})
+		r.logger.ErrorContext(ctx, "Outbox event dead-lettered", fields)
+		return nil
+	}
+
+	query := `UPDATE outbox_events
+        SET attempts = $2, last_error = $3, next_attempt_at = $4
+        WHERE id = $1`
+	if _, err := conn.ExecContext(ctx, query, event.rowID, attempts, cause.Error(), time.Now().Add(r.backoff(attempts))); err != nil {
+		return fmt.Errorf("mark event failed: %w", err)
+	}
+	r.metrics.IncrementCounter(ports.MetricOutboxPublished, map[string]string{
+		"type":    string(event.Type),
+		"outcome": ports.OutcomeRetry,
+	})
+	r.logger.WarnContext(ctx, "Failed to publish outbox event", fields)
+	return nil
+}
+
+// backoff is the delay after the given number of failed attempts
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.settings.BaseBackoff
+	for i := 1; i < attempts && delay < r.settings.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.settings.MaxBackoff)
+}
+
+func (r *OutboxRelay) updatePending(ctx context.Context, conn *sql.Conn) {
+	var pending int64
+	query := `SELECT COUNT(*) FROM outbox_events WHERE published_at IS NULL AND dead_at IS NULL`
+	if err := conn.QueryRowContext(ctx, query).Scan(&pending); err != nil {
+		return
+	}
+	r.metrics.SetGauge(ports.MetricOutboxPending, float64(pending), nil)
+}
+
+func (r *OutboxRelay) cleanup(ctx context.Context, conn *sql.Conn) {
+	query := `DELETE FROM outbox_events WHERE published_at < $1`
+
+	result, err := conn.ExecContext(ctx, query, time.Now().Add(-r.settings.Retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.ErrorContext(ctx, "Failed to clean up outbox events", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		r.logger.Info("Cleaned up published outbox events", map[string]interface{}{
+			"deleted": deleted,
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+)
+
+// Seconds the replica is behind, 0 when it has replayed everything it received
+const replicaLagQuery = `SELECT CASE
+        WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+        ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+    END`
+
+type Replica struct {
+	// Label in logs and metrics, e.g. the host
+	Name string
+	DB   *sql.DB
+}
+
+type ReplicaSettings struct {
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+	// Replicas further behind are skipped until they catch up
+	MaxLag time.Duration
+}
+
+// ReplicaSet sends reads to healthy replicas in turn and everything else
+// to the primary. Reads fall back to the primary while no replica is healthy
+// or when the context asks for it with requestctx.WithPrimary
+type ReplicaSet struct {
+	primary  *sql.DB
+	replicas []Replica
+	logger   ports.LoggerPort
+	metrics  ports.MetricsPort
+	settings ReplicaSettings
+
+	healthy atomic.Pointer[[]*sql.DB]
+	next    atomic.Uint64
+}
+
+func NewReplicaSet(primary *sql.DB, replicas []Replica, logger ports.LoggerPort, metrics ports.MetricsPort, settings ReplicaSettings) *ReplicaSet {
+	s := &ReplicaSet{
+		primary:  primary,
+		replicas: replicas,
+		logger:   logger,
+		metrics:  metrics,
+		settings: settings,
+	}
+	s.healthy.Store(&[]*sql.DB{})
+	return s
+}
+
+// Primary returns the database for writes and read-after-write paths
+func (s *ReplicaSet) Primary() *sql.DB {
+	return s.primary
+}
+
+func (s *ReplicaSet) Reader(ctx context.Context) *sql.DB {
+	if requestctx.PrimaryRequired(ctx) {
+		return s.primary
+	}
+
+	healthy := *s.healthy.Load()
+	if len(healthy) == 0 {
+		return s.primary
+	}
+	return healthy[s.next.Add(1)%uint64(len(healthy))]
+}
+
+// Run checks the replicas until ctx is cancelled.
+// Until the first check completes all reads go to the primary
+func (s *ReplicaSet) Run(ctx context.Context) {
+	if len(s.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.settings.CheckInterval)
+	defer ticker.Stop()
+
+	wasHealthy := make(map[string]bool, len(s.replicas))
+	for {
+		healthy := make([]*sql.DB, 0, len(s.replicas))
+		for _, replica := range s.replicas {
+			err := s.check(ctx, replica.DB)
+			if ctx.Err() != nil {
+				return
+			}
+
+			ok := err == nil
+			if ok {
+				healthy = append(healthy, replica.DB)
+			}
+			if previous, seen := wasHealthy[replica.Name]; !seen || previous != ok {
+				s.logStateChange(ctx, replica.Name, err)
+			}
+			wasHealthy[replica.Name] = ok
+
+			value := 0.0
+			if ok {
+				value = 1
+			}
+			s.metrics.SetGauge(ports.MetricDBReplicaHealthy, value, map[string]string{
+				"replica": replica.Name,
+			})
+		}
+		s.healthy.Store(&healthy)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ReplicaSet) check(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, s.settings.CheckTimeout)
+	defer cancel()
+
+	var lagSeconds float64
+	if err := db.QueryRowContext(ctx, replicaLagQuery).Scan(&lagSeconds); err != nil {
+		return err
+	}
+
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	if s.settings.MaxLag > 0 && lag > s.settings.MaxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), s.settings.MaxLag)
+	}
+	return nil
+}
+
+func (s *ReplicaSet) logStateChange(ctx context.Context, name string, err error) {
+	if err == nil {
+		s.logger.InfoContext(ctx, "Read replica is healthy", map[string]interface{}{
+			"replica": name,
+		})
+		return
+	}
+	s.logger.WarnContext(ctx, "Read replica is unhealthy, reads skip it", map[string]interface{}{
+		"replica": name,
+		"error":   err.Error(),
+	})
+}
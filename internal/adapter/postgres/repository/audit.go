@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+)
+
+type PostgresAuditRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewAuditRepository(db *sql.DB, queryTimeout time.Duration) *PostgresAuditRepository {
+	return &PostgresAuditRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (r *PostgresAuditRepository) CreateEvent(ctx context.Context, event *domain.AuditEvent) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	changes, err := json.Marshal(nonNilChanges(event.Changes))
+	if err != nil {
+		return fmt.Errorf("marshal audit changes: %w", err)
+	}
+	details, err := json.Marshal(nonNilDetails(event.Details))
+	if err != nil {
+		return fmt.Errorf("marshal audit details: %w", err)
+	}
+
+	query := `INSERT INTO audit_events (action, actor_id, target_id, changes, details, ip, user_agent, request_id)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING id, occurred_at`
+
+	// In a savepoint, so a failed insert leaves the audited transaction usable
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query,
+			event.Action,
+			nullUUID(event.ActorID),
+			nullUUID(event.TargetID),
+			changes,
+			details,
+			event.IP,
+			event.UserAgent,
+			event.RequestID,
+		).Scan(&event.ID, &event.OccurredAt)
+	})
+}
+
+func (r *PostgresAuditRepository) ListEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		where("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		where("target_id = $%d", *filter.TargetID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		where("occurred_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("occurred_at < $%d", filter.To)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM audit_events ` + whereClause
+	if err := conn(ctx, r.db).QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT id, occurred_at, action, actor_id, target_id, changes, details, ip, user_agent, request_id
+              FROM audit_events %s
+              ORDER BY occurred_at DESC, id
+              LIMIT $%d OFFSET $%d`, whereClause, len(args)+1, len(args)+2)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := make([]domain.AuditEvent, 0, filter.Limit)
+	for rows.Next() {
+		var (
+			event             domain.AuditEvent
+			actorID, targetID uuid.NullUUID
+			changes, details  []byte
+		)
+		if err := rows.Scan(
+			&event.ID,
+			&event.OccurredAt,
+			&event.Action,
+			&actorID,
+			&targetID,
+			&changes,
+			&details,
+			&event.IP,
+			&event.UserAgent,
+			&event.RequestID,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if actorID.Valid {
+			event.ActorID = &actorID.UUID
+		}
+		if targetID.Valid {
+			event.TargetID = &targetID.UUID
+		}
+		if err := json.Unmarshal(changes, &event.Changes); err != nil {
+			return nil, 0, fmt.Errorf("unmarshal audit changes: %w", err)
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return nil, 0, fmt.Errorf("unmarshal audit details: %w", err)
+		}
+
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+func nullUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+func nonNilChanges(changes map[string]domain.AuditChange) map[string]domain.AuditChange {
+	if changes == nil {
+		return map[string]domain.AuditChange{}
+	}
+	return changes
+}
+
+func nonNilDetails(details map[string]string) map[string]string {
+	if details == nil {
+		return map[string]string{}
+	}
+	return details
+}
+
+var _ ports.AuditRepository = (*PostgresAuditRepository)(nil)
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+)
+
+const userDeletionColumns = `id, user_id, status, attempts, next_attempt_at, last_error,
+        requested_by, created_at, updated_at, completed_at`
+
+type PostgresUserDeletionRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewUserDeletionRepository(db *sql.DB, queryTimeout time.Duration) *PostgresUserDeletionRepository {
+	return &PostgresUserDeletionRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (r *PostgresUserDeletionRepository) StartDeletion(ctx context.Context, userID uuid.UUID, requestedBy *uuid.UUID, lease time.Duration) (*domain.UserDeletion, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var deletion *domain.UserDeletion
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE users
+            SET status = $1, updated_at = CURRENT_TIMESTAMP
+            WHERE id = $2 AND status = $3`,
+			string(domain.UserPendingDeletion), userID, string(domain.UserActive))
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return r.notStartable(ctx, tx, userID)
+		}
+
+		// Leased from the start, otherwise a worker may claim it while the caller advances it
+		query := `INSERT INTO user_deletions (user_id, requested_by, next_attempt_at)
+            VALUES ($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond')
+            RETURNING ` + userDeletionColumns
+
+		deletion, err = scanUserDeletion(tx.QueryRowContext(ctx, query, userID, requestedBy, lease.Milliseconds()))
+		return err
+	})
+	if err != nil {
+		if pgErrorCode(err) == "23505" {
+			return nil, domain.ErrDeletionInProgress
+		}
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// notStartable tells a missing user from one already being deleted
+func (r *PostgresUserDeletionRepository) notStartable(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrDeletionInProgress
+}
+
+func (r *PostgresUserDeletionRepository) GetDeletion(ctx context.Context, id uuid.UUID) (*domain.UserDeletion, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + userDeletionColumns + ` FROM user_deletions WHERE id = $1`
+
+	return scanUserDeletion(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresUserDeletionRepository) ListDeletions(ctx context.Context, filter domain.DeletionFilter) ([]domain.UserDeletion, int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM user_deletions`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM user_deletions%s
+        ORDER BY created_at DESC
+        LIMIT $%d OFFSET $%d`, userDeletionColumns, where, len(args)-1, len(args))
+
+	deletions, err := r.listDeletions(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deletions, total, nil
+}
+
+// ClaimDueDeletions hides the returned deletions from other replicas for lease
+func (r *PostgresUserDeletionRepository) ClaimDueDeletions(ctx context.Context, limit int, lease time.Duration) ([]domain.UserDeletion, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE user_deletions
+        SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+        WHERE id IN (
+            SELECT id FROM user_deletions
+            WHERE status IN ('pending', 'bikes_removed') AND next_attempt_at <= CURRENT_TIMESTAMP
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + userDeletionColumns
+
+	return r.listDeletions(ctx, query, limit, lease.Milliseconds())
+}
+
+func (r *PostgresUserDeletionRepository) SaveDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return saveUserDeletion(ctx, r.db, deletion, from)
+}
+
+func (r *PostgresUserDeletionRepository) FinalizeDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		// Saved first so a worker that lost the race leaves the user alone
+		if err := saveUserDeletion(ctx, tx, deletion, from); err != nil {
+			return err
+		}
+		// Gone already when an earlier attempt committed but failed to report it
+		if err := deleteUserTx(ctx, tx, deletion.UserID); err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *PostgresUserDeletionRepository) CompensateDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if err := saveUserDeletion(ctx, tx, deletion, from); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE users
+            SET status = $1, updated_at = CURRENT_TIMESTAMP
+            WHERE id = $2 AND status = $3`,
+			string(domain.UserActive), deletion.UserID, string(domain.UserPendingDeletion))
+		return err
+	})
+}
+
+// saveUserDeletion writes deletion if it is still stored with status from
+func saveUserDeletion(ctx context.Context, db dbtx, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	query := `UPDATE user_deletions
+        SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5,
+        completed_at = $6, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND status = $7`
+
+	result, err := db.ExecContext(ctx, query,
+		deletion.ID,
+		string(deletion.Status),
+		deletion.Attempts,
+		deletion.NextAttemptAt,
+		deletion.LastError,
+		deletion.CompletedAt,
+		string(from),
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return deletionConflict(ctx, db, deletion.ID)
+	}
+	return nil
+}
+
+// deletionConflict tells a missing deletion from one another worker moved on
+func deletionConflict(ctx context.Context, db dbtx, id uuid.UUID) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM user_deletions WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrDeletionNotFound
+	}
+	return domain.ErrDeletionConflict
+}
+
+func (r *PostgresUserDeletionRepository) listDeletions(ctx context.Context, query string, args ...interface{}) ([]domain.UserDeletion, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deletions := []domain.UserDeletion{}
+	for rows.Next() {
+		deletion, err := scanUserDeletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, *deletion)
+	}
+	return deletions, rows.Err()
+}
+
+func scanUserDeletion(row rowScanner) (*domain.UserDeletion, error) {
+	var (
+		deletion    domain.UserDeletion
+		status      string
+		requestedBy uuid.NullUUID
+		completedAt sql.NullTime
+	)
+	err := row.Scan(
+		&deletion.ID,
+		&deletion.UserID,
+		&status,
+		&deletion.Attempts,
+		&deletion.NextAttemptAt,
+		&deletion.LastError,
+		&requestedBy,
+		&deletion.CreatedAt,
+		&deletion.UpdatedAt,
+		&completedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrDeletionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deletion.Status = domain.DeletionStatus(status)
+	if requestedBy.Valid {
+		deletion.RequestedBy = &requestedBy.UUID
+	}
+	if completedAt.Valid {
+		deletion.CompletedAt = &completedAt.Time
+	}
+	return &deletion, nil
+}
+
+var _ ports.UserDeletionRepository = (*PostgresUserDeletionRepository)(nil)
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// pgErrorCode is the SQLSTATE of err for either driver, empty for other errors
+func pgErrorCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// translateUserError maps constraint violations of a user insert to domain errors
+func translateUserError(err error) error {
+	switch pgErrorCode(err) {
+	case "23505":
+		return domain.ErrEmailAlreadyExists
+	case "23502":
+		return fmt.Errorf("required field is missing")
+	default:
+		return err
+	}
+}
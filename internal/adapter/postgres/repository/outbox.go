@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+// insertOutboxEvent stores the event next to the change that caused it,
+// the relay publishes it after commit
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType domain.EventType, payload domain.UserEventPayload) error {
+	args, err := outboxEventArgs(eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, insertOutboxEventQuery, args...); err != nil {
+		return fmt.Errorf("insert %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+const insertOutboxEventQuery = `INSERT INTO outbox_events (event_id, aggregate_id, event_type, payload, created_at)
+        VALUES ($1, $2, $3, $4, $5)`
+
+func outboxEventArgs(eventType domain.EventType, payload domain.UserEventPayload) ([]interface{}, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s event: %w", eventType, err)
+	}
+	return []interface{}{uuid.New(), payload.ID, string(eventType), data, time.Now().UTC()}, nil
+}
+
+func userEventPayload(user *domain.User) domain.UserEventPayload {
+	return domain.UserEventPayload{
+		ID:          user.ID,
+		Name:        user.Name,
+		Email:       user.Email,
+		DateOfBirth: user.DateOfBirth,
+		Role:        user.Role,
+	}
+}
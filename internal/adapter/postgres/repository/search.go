@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+)
+
+// SearchUsers matches name and email prefixes through search_vector and
+// misspellings through pg_trgm word similarity, best matches first
+func (r *PostgresUserRepository) SearchUsers(ctx context.Context, filter domain.UserSearchFilter) ([]domain.UserSearchHit, int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	countQuery, countArgs, query, args := searchUsersQueries(filter)
+	reader, _ := r.reader(ctx)
+
+	var total int
+	if err := reader.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	hits := []domain.UserSearchHit{}
+	for rows.Next() {
+		var hit domain.UserSearchHit
+		err := rows.Scan(
+			&hit.User.ID,
+			&hit.User.Name,
+			&hit.User.DateOfBirth,
+			&hit.User.Email,
+			&hit.User.Password,
+			&hit.User.CreatedAt,
+			&hit.User.UpdatedAt,
+			&hit.User.Role,
+			&hit.User.Status,
+			&hit.Rank,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+// searchUsersQueries builds the count and the page query of a search
+func searchUsersQueries(filter domain.UserSearchFilter) (countQuery string, countArgs []interface{}, query string, args []interface{}) {
+	terms := append([]string{filter.Query}, filter.Variants...)
+
+	args = []interface{}{prefixTSQuery(terms)}
+	conditions := []string{`search_vector @@ to_tsquery('simple', $1)`}
+	ranks := []string{`ts_rank(search_vector, to_tsquery('simple', $1))`}
+	for _, term := range terms {
+		args = append(args, term)
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf(`$%d <%% name OR $%d <%% email`, n, n))
+		ranks = append(ranks, fmt.Sprintf(`word_similarity($%d, name)`, n), fmt.Sprintf(`word_similarity($%d, email)`, n))
+	}
+	where := " WHERE " + strings.Join(conditions, " OR ")
+
+	countQuery = `SELECT COUNT(*) FROM users` + where
+	countArgs = args
+	args = append(args[:len(args):len(args)], filter.Limit, filter.Offset)
+	query = fmt.Sprintf(`SELECT id, name, date_of_birth, email, password, created_at, updated_at, role, status,
+        GREATEST(%s) AS rank
+        FROM users%s
+        ORDER BY rank DESC, name, id
+        LIMIT $%d OFFSET $%d`, strings.Join(ranks, ", "), where, len(args)-1, len(args))
+	return countQuery, countArgs, query, args
+}
+
+// prefixTSQuery turns every term into "word:* & word:*" and ORs the terms.
+// Only letters and digits are kept, so the result is always valid tsquery syntax
+func prefixTSQuery(terms []string) string {
+	alternatives := make([]string, 0, len(terms))
+	for _, term := range terms {
+		words := strings.FieldsFunc(strings.ToLower(term), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = word + ":*"
+		}
+		alternatives = append(alternatives, "("+strings.Join(words, " & ")+")")
+	}
+	return strings.Join(alternatives, " | ")
+}
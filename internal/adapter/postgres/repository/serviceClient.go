@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const serviceClientColumns = `id, name, scopes, secret_hash, created_at, rotated_at, revoked_at`
+
+type PostgresServiceClientRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewServiceClientRepository(db *sql.DB, queryTimeout time.Duration) *PostgresServiceClientRepository {
+	return &PostgresServiceClientRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (r *PostgresServiceClientRepository) CreateClient(ctx context.Context, client *domain.ServiceClient) (*domain.ServiceClient, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `INSERT INTO service_clients (name, scopes, secret_hash)
+    VALUES ($1, $2, $3)
+    RETURNING ` + serviceClientColumns
+
+	return scanServiceClient(conn(ctx, r.db).QueryRowContext(ctx, query,
+		client.Name, pq.Array(client.Scopes), client.SecretHash))
+}
+
+func (r *PostgresServiceClientRepository) GetClientByID(ctx context.Context, id uuid.UUID) (*domain.ServiceClient, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + serviceClientColumns + ` FROM service_clients WHERE id = $1`
+
+	return scanServiceClient(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresServiceClientRepository) ListClients(ctx context.Context) ([]domain.ServiceClient, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + serviceClientColumns + ` FROM service_clients ORDER BY created_at`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []domain.ServiceClient{}
+	for rows.Next() {
+		client, err := scanServiceClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+	return clients, rows.Err()
+}
+
+// Revoked clients keep their secret, rotating them brings nothing back
+func (r *PostgresServiceClientRepository) UpdateClientSecret(ctx context.Context, id uuid.UUID, secretHash string) (*domain.ServiceClient, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE service_clients
+        SET secret_hash = $1, rotated_at = CURRENT_TIMESTAMP
+        WHERE id = $2 AND revoked_at IS NULL
+        RETURNING ` + serviceClientColumns
+
+	return scanServiceClient(conn(ctx, r.db).QueryRowContext(ctx, query, secretHash, id))
+}
+
+func (r *PostgresServiceClientRepository) RevokeClient(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE service_clients
+        SET revoked_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrServiceClientNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanServiceClient(row rowScanner) (*domain.ServiceClient, error) {
+	var (
+		client               domain.ServiceClient
+		rotatedAt, revokedAt sql.NullTime
+	)
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		pq.Array(&client.Scopes),
+		&client.SecretHash,
+		&client.CreatedAt,
+		&rotatedAt,
+		&revokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrServiceClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rotatedAt.Valid {
+		client.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		client.RevokedAt = &revokedAt.Time
+	}
+	return &client, nil
+}
+
+var _ ports.ServiceClientRepository = (*PostgresServiceClientRepository)(nil)
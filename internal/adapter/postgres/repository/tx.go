@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// dbtx is what *sql.DB and *sql.Tx have in common
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx *sql.Tx
+	// Connection of tx, for statements sent through the native driver
+	conn *sql.Conn
+	// Savepoints opened above the transaction
+	depth int
+	level ports.IsolationLevel
+}
+
+func txFromContext(ctx context.Context) *txState {
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
+
+// conn returns the transaction carried by ctx, or db outside of one
+func conn(ctx context.Context, db *sql.DB) dbtx {
+	if state := txFromContext(ctx); state != nil {
+		return state.tx
+	}
+	return db
+}
+
+type TxSettings struct {
+	// Times a transaction is run again after a serialization failure or deadlock
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+}
+
+// TxManager runs units of work that span repositories.
+// Repositories find the transaction in the context, so they need no changes
+// to take part, and their own transactions become savepoints inside it
+type TxManager struct {
+	db       *sql.DB
+	settings TxSettings
+}
+
+func NewTxManager(db *sql.DB, settings TxSettings) *TxManager {
+	return &TxManager{
+		db:       db,
+		settings: settings,
+	}
+}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxIsolation(ctx, ports.IsolationDefault, fn)
+}
+
+func (m *TxManager) WithinTxIsolation(ctx context.Context, level ports.IsolationLevel, fn func(ctx context.Context) error) error {
+	// Only the outermost call may retry, the transaction is gone by then
+	if state := txFromContext(ctx); state != nil {
+		// Postgres fixes the level at the first statement
+		if level > state.level {
+			return fmt.Errorf("cannot raise isolation to %s inside a %s transaction", sqlIsolation(level), sqlIsolation(state.level))
+		}
+		return withSavepoint(ctx, state, fn)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, m.db, level, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= m.settings.MaxRetries {
+			return err
+		}
+
+		if waitErr := sleep(ctx, m.backoff(attempt)); waitErr != nil {
+			return err
+		}
+	}
+}
+
+// Full jitter: a random delay up to base * 2^attempt
+func (m *TxManager) backoff(attempt int) time.Duration {
+	ceiling := m.settings.RetryBaseDelay << attempt
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// withTx runs fn in a transaction, committing only when fn succeeds.
+// Inside a TxManager transaction it runs in a savepoint instead
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if state := txFromContext(ctx); state != nil {
+		return withSavepoint(ctx, state, func(context.Context) error {
+			return fn(state.tx)
+		})
+	}
+	return runTx(ctx, db, ports.IsolationDefault, func(ctx context.Context) error {
+		return fn(txFromContext(ctx).tx)
+	})
+}
+
+func runTx(ctx context.Context, db *sql.DB, level ports.IsolationLevel, fn func(ctx context.Context) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sqlIsolation(level)})
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx, conn: conn, level: level})); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// withSavepoint undoes only the work of fn when it fails,
+// the enclosing transaction stays usable
+func withSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) error {
+	nested := &txState{tx: state.tx, conn: state.conn, depth: state.depth + 1, level: state.level}
+	name := fmt.Sprintf("sp_%d", nested.depth)
+
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, nested)); err != nil {
+		if _, rollbackErr := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	_, err := state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+func sqlIsolation(level ports.IsolationLevel) sql.IsolationLevel {
+	switch level {
+	case ports.IsolationRepeatableRead:
+		return sql.LevelRepeatableRead
+	case ports.IsolationSerializable:
+		return sql.LevelSerializable
+	default:
+		return sql.LevelDefault
+	}
+}
+
+// Serialization failures and deadlocks succeed when simply run again
+func isRetryableTxError(err error) bool {
+	code := pgErrorCode(err)
+	return code == "40001" || code == "40P01"
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+var _ ports.TransactionManager = (*TxManager)(nil)
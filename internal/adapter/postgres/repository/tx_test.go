@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// statementLog is what the fake driver saw, statements run in a transaction are prefixed with "tx: "
+type statementLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *statementLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *statementLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+// fakeConnector hands out connections that log statements instead of running them
+type fakeConnector struct {
+	log *statementLog
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{log: c.log}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	log  *statementLog
+	inTx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	entry := "BEGIN"
+	if level := sql.IsolationLevel(opts.Isolation); level != sql.LevelDefault {
+		entry += " " + level.String()
+	}
+	c.log.add(entry)
+	c.inTx = true
+	return &fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	// Only the first line, repository queries span several
+	query, _, _ = strings.Cut(query, "\n")
+	if c.inTx {
+		query = "tx: " + query
+	}
+	c.log.add(query)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (t *fakeTx) Commit() error {
+	t.conn.inTx = false
+	t.conn.log.add("COMMIT")
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.conn.inTx = false
+	t.conn.log.add("ROLLBACK")
+	return nil
+}
+
+func newTestTxManager(t *testing.T, maxRetries int) (*TxManager, *sql.DB, *statementLog) {
+	t.Helper()
+
+	log := &statementLog{}
+	db := sql.OpenDB(fakeConnector{log: log})
+	t.Cleanup(func() { db.Close() })
+
+	return NewTxManager(db, TxSettings{
+		MaxRetries:     maxRetries,
+		RetryBaseDelay: time.Millisecond,
+	}), db, log
+}
+
+func exec(ctx context.Context, db *sql.DB, query string) error {
+	_, err := conn(ctx, db).ExecContext(ctx, query)
+	return err
+}
+
+func TestTxManager_NestedCallsUseSavepoints(t *testing.T) {
+	m, db, log := newTestTxManager(t, 0)
+	errInner := errors.New("inner failed")
+
+	err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := exec(ctx, db, "INSERT outer"); err != nil {
+			return err
+		}
+
+		// A failed nested call only undoes its own work
+		err := m.WithinTx(ctx, func(ctx context.Context) error {
+			if err := exec(ctx, db, "INSERT failed"); err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			t.Errorf("nested err = %v, want %v", err, errInner)
+		}
+
+		return m.WithinTx(ctx, func(ctx context.Context) error {
+			return m.WithinTx(ctx, func(ctx context.Context) error {
+				return exec(ctx, db, "INSERT kept")
+			})
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	want := []string{
+		"BEGIN",
+		"tx: INSERT outer",
+		"tx: SAVEPOINT sp_1",
+		"tx: INSERT failed",
+		"tx: ROLLBACK TO SAVEPOINT sp_1",
+		"tx: SAVEPOINT sp_1",
+		"tx: SAVEPOINT sp_2",
+		"tx: INSERT kept",
+		"tx: RELEASE SAVEPOINT sp_2",
+		"tx: RELEASE SAVEPOINT sp_1",
+		"COMMIT",
+	}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("statements:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestTxManager_Retries(t *testing.T) {
+	errOther := errors.New("constraint violated")
+
+	tests := []struct {
+		name string
+		// Errors of consecutive attempts, later attempts succeed
+		errs         []error
+		maxRetries   int
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "serialization failure",
+			errs:         []error{&pgconn.PgError{Code: "40001"}},
+			maxRetries:   3,
+			wantAttempts: 2,
+		},
+		{
+			name:         "deadlock",
+			errs:         []error{&pgconn.PgError{Code: "40P01"}, &pgconn.PgError{Code: "40P01"}},
+			maxRetries:   3,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives up after max retries",
+			errs:         []error{&pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40001"}},
+			maxRetries:   2,
+			wantAttempts: 3,
+			wantErr:      &pgconn.PgError{Code: "40001"},
+		},
+		{
+			name:         "other database error",
+			errs:         []error{&pgconn.PgError{Code: "23505"}},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr:      &pgconn.PgError{Code: "23505"},
+		},
+		{
+			name:         "application error",
+			errs:         []error{errOther},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr:      errOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _, log := newTestTxManager(t, tt.maxRetries)
+
+			attempts := 0
+			err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+			if tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			// Every failed attempt is rolled back before the next one begins
+			entries := log.get()
+			if begins := countEntries(entries, "BEGIN"); begins != tt.wantAttempts {
+				t.Errorf("%d transactions begun, want %d: %q", begins, tt.wantAttempts, entries)
+			}
+			wantCommits := 0
+			if tt.wantErr == nil {
+				wantCommits = 1
+			}
+			if commits := countEntries(entries, "COMMIT"); commits != wantCommits {
+				t.Errorf("%d commits, want %d: %q", commits, wantCommits, entries)
+			}
+		})
+	}
+}
+
+func TestTxManager_NestedCallsDoNotRetry(t *testing.T) {
+	m, _, _ := newTestTxManager(t, 3)
+
+	outer, inner := 0, 0
+	_ = m.WithinTx(context.Background(), func(ctx context.Context) error {
+		outer++
+		_ = m.WithinTx(ctx, func(ctx context.Context) error {
+			inner++
+			return &pgconn.PgError{Code: "40001"}
+		})
+		return nil
+	})
+
+	if outer != 1 || inner != 1 {
+		t.Errorf("outer ran %d times, inner %d, want once each", outer, inner)
+	}
+}
+
+func TestTxManager_Isolation(t *testing.T) {
+	m, _, log := newTestTxManager(t, 0)
+	ctx := context.Background()
+
+	err := m.WithinTxIsolation(ctx, ports.IsolationSerializable, func(ctx context.Context) error {
+		// Joining at a lower level is fine
+		return m.WithinTx(ctx, func(ctx context.Context) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("WithinTxIsolation: %v", err)
+	}
+	if got := log.get()[0]; got != "BEGIN Serializable" {
+		t.Errorf("first statement = %q, want a serializable transaction", got)
+	}
+
+	err = m.WithinTx(ctx, func(ctx context.Context) error {
+		return m.WithinTxIsolation(ctx, ports.IsolationRepeatableRead, func(ctx context.Context) error {
+			t.Error("nested call ran at a level the transaction does not have")
+			return nil
+		})
+	})
+	if err == nil {
+		t.Error("raising the isolation of a running transaction succeeded")
+	}
+}
+
+// Repositories pick the transaction up from the context:
+// plain statements run in it and their own transactions become savepoints
+func TestTxManager_RepositoriesJoinTransaction(t *testing.T) {
+	m, db, log := newTestTxManager(t, 0)
+	repo := NewWebhookRepository(db, time.Second)
+
+	err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := repo.DeleteSubscription(ctx, uuid.New()); err != nil {
+			return err
+		}
+		return repo.EnqueueDeliveries(ctx, []domain.WebhookDelivery{{
+			SubscriptionID: uuid.New(),
+			Event: domain.Event{
+				ID:          uuid.New(),
+				Type:        domain.EventUserRegistered,
+				AggregateID: uuid.New(),
+				OccurredAt:  time.Now(),
+				Payload:     []byte(`{}`),
+			},
+		}})
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	want := []string{
+		"BEGIN",
+		"tx: DELETE FROM webhook_subscriptions WHERE id = $1",
+		"tx: SAVEPOINT sp_1",
+		"tx: INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, aggregate_id, occurred_at, payload)",
+		"tx: RELEASE SAVEPOINT sp_1",
+		"COMMIT",
+	}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("statements:\n got %q\nwant %q", got, want)
+	}
+
+	// Outside of WithinTx the same call runs on its own
+	if err := repo.DeleteSubscription(context.Background(), uuid.New()); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	if got := log.get(); got[len(got)-1] != "DELETE FROM webhook_subscriptions WHERE id = $1" {
+		t.Errorf("last statement = %q, want it outside a transaction", got[len(got)-1])
+	}
+}
+
+func countEntries(entries []string, prefix string) int {
+	count := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, prefix) {
+			count++
+		}
+	}
+	return count
+}
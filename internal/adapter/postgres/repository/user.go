@@ -4,67 +4,155 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/postgres"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+// PostgresUserRepository writes to the primary and reads from replicas,
+// except where a read must see a write made just before
 type PostgresUserRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	dbs          *postgres.ReplicaSet
+	queryTimeout time.Duration
 }
 
-func NewUserRepository(db *sql.DB) *PostgresUserRepository {
+func NewUserRepository(dbs *postgres.ReplicaSet, queryTimeout time.Duration) *PostgresUserRepository {
 	return &PostgresUserRepository{
-		db,
+		db:           dbs.Primary(),
+		dbs:          dbs,
+		queryTimeout: queryTimeout,
 	}
 }
 
 func (r *PostgresUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `INSERT INTO users (name, date_of_birth, email, password, role)
     VALUES ($1, $2, $3, $4, $5)
-    RETURNING id, created_at, updated_at`
+    RETURNING id, created_at, updated_at, role, status`
 
-	err := r.db.QueryRowContext(ctx, query, user.Name, user.DateOfBirth, user.Email, user.Password, user.Role).Scan(
-		&user.ID,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, user.Name, user.DateOfBirth, user.Email, user.Password, user.Role).Scan(
+			&user.ID,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Role,
+			&user.Status,
+		)
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, domain.EventUserRegistered, userEventPayload(user))
+	})
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code {
-			case "23505":
-				return nil, fmt.Errorf("email already exists")
-			case "23502":
-				return nil, fmt.Errorf("required field is missing")
-			default:
-				return nil, err
+		return nil, translateUserError(err)
+	}
+	return user, nil
+}
+
+// ImportUsers streams the users with COPY, events are recorded in the same transaction
+func (r *PostgresUserRepository) ImportUsers(ctx context.Context, users []domain.User) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	prepareImportedUsers(users)
+
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn("users", importUserColumns...))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i := range users {
+			if _, err := stmt.ExecContext(ctx, importUserValues(&users[i])...); err != nil {
+				return err
 			}
 		}
-		return nil, err
+		// Flushes the buffered rows
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			return err
+		}
+
+		for i := range users {
+			if err := insertOutboxEvent(ctx, tx, domain.EventUserRegistered, userEventPayload(&users[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return translateUserError(err)
+	}
+	return nil
+}
+
+var importUserColumns = []string{"id", "name", "date_of_birth", "email", "password", "created_at", "updated_at", "role", "status"}
+
+// COPY writes every column, so the table defaults are applied here
+func prepareImportedUsers(users []domain.User) {
+	now := time.Now().UTC()
+	for i := range users {
+		user := &users[i]
+		if user.ID == uuid.Nil {
+			user.ID = uuid.New()
+		}
+		if user.Role == "" {
+			user.Role = domain.AppUser
+		}
+		if user.Status == "" {
+			user.Status = domain.UserActive
+		}
+		user.CreatedAt = now
+		user.UpdatedAt = now
 	}
-	return user, nil
 }
 
+func importUserValues(user *domain.User) []interface{} {
+	return []interface{}{
+		user.ID,
+		user.Name,
+		user.DateOfBirth,
+		user.Email,
+		user.Password,
+		user.CreatedAt,
+		user.UpdatedAt,
+		string(user.Role),
+		string(user.Status),
+	}
+}
+
+// reader is the transaction in ctx, so it sees its own writes,
+// otherwise a connection picked by the replica set
+func (r *PostgresUserRepository) reader(ctx context.Context) (_ dbtx, replica bool) {
+	if state := txFromContext(ctx); state != nil {
+		return state.tx, false
+	}
+	db := r.dbs.Reader(ctx)
+	return db, db != r.db
+}
+
+// A replica that has not seen the user yet is double-checked on the primary,
+// so a user is found right after registration
 func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
-	query := `SELECT id, name, date_of_birth, email, password, created_at, updated_at, role
-              FROM users WHERE id = $1`
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
 
-	user := &domain.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Name,
-		&user.DateOfBirth,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Role,
-	)
+	query := selectUserQuery + ` WHERE id = $1`
 
+	reader, replica := r.reader(ctx)
+	user, err := scanUser(reader.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows && replica {
+		user, err = scanUser(r.db.QueryRowContext(ctx, query, id))
+	}
 	if err == sql.ErrNoRows {
-		return nil, err
+		return nil, domain.ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -73,27 +161,61 @@ func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id uuid.UUID)
 	return user, nil
 }
 
-func (r *PostgresUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
+// GetUsersByIDs returns the users found, in no particular order
+func (r *PostgresUserRepository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	reader, _ := r.reader(ctx)
+	rows, err := reader.QueryContext(ctx, selectUserQuery+` WHERE id = ANY($1)`, pq.Array(ids))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	users := make([]domain.User, 0, len(ids))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
 	}
+	return users, rows.Err()
+}
+
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		return deleteUserTx(ctx, tx, id)
+	})
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("User not found")
+// deleteUserTx deletes the user and records UserDeleted in tx
+func deleteUserTx(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	query := `DELETE FROM users WHERE id = $1 RETURNING email`
+
+	var email string
+	err := tx.QueryRowContext(ctx, query, id).Scan(&email)
+	if err == sql.ErrNoRows {
+		return domain.ErrUserNotFound
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return insertOutboxEvent(ctx, tx, domain.EventUserDeleted, domain.UserEventPayload{
+		ID:    id,
+		Email: email,
+	})
 }
 
 func (r *PostgresUserRepository) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `UPDATE users
         SET 
         name = COALESCE(NULLIF($1, ''), name),
@@ -102,35 +224,131 @@ func (r *PostgresUserRepository) UpdateUser(ctx context.Context, user *domain.Us
         password = COALESCE(NULLIF($4, ''), password),
         updated_at = CURRENT_TIMESTAMP
         WHERE id = $5
-        RETURNING id, name, date_of_birth, email, password, created_at, updated_at, role`
+        RETURNING id, name, date_of_birth, email, password, created_at, updated_at, role, status`
 
 	result := &domain.User{}
-	err := r.db.QueryRowContext(ctx, query,
-		user.Name, user.DateOfBirth, user.Email, user.Password, user.ID).Scan(
-		&result.ID,
-		&result.Name,
-		&result.DateOfBirth,
-		&result.Email,
-		&result.Password,
-		&result.CreatedAt,
-		&result.UpdatedAt,
-		&result.Role,
-	)
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		// Locked so the event describes exactly this change
+		before, err := scanUser(tx.QueryRowContext(ctx, selectUserQuery+` WHERE id = $1 FOR UPDATE`, user.ID))
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, query,
+			user.Name, user.DateOfBirth, user.Email, user.Password, user.ID).Scan(
+			&result.ID,
+			&result.Name,
+			&result.DateOfBirth,
+			&result.Email,
+			&result.Password,
+			&result.CreatedAt,
+			&result.UpdatedAt,
+			&result.Role,
+			&result.Status,
+		)
+		if err != nil {
+			return err
+		}
+
+		changed := changedUserFields(before, result)
+		if len(changed) == 0 {
+			return nil
+		}
+		payload := userEventPayload(result)
+		payload.ChangedFields = changed
+		if before.Email != result.Email {
+			payload.PreviousEmail = before.Email
+		}
+		return insertOutboxEvent(ctx, tx, domain.EventUserUpdated, payload)
+	})
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return nil, fmt.Errorf("email already exists")
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrUserNotFound
+		}
+		if pgErrorCode(err) == "23505" {
+			return nil, domain.ErrEmailAlreadyExists
 		}
 		return nil, fmt.Errorf("Error updating user: %w", err)
 	}
 	return result, nil
 }
+
 func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `SELECT id, name, date_of_birth, email, password, created_at, updated_at, role
-              FROM users WHERE email = $1`
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := selectUserQuery + ` WHERE email = $1`
+
+	reader, replica := r.reader(ctx)
+	user, err := scanUser(reader.QueryRowContext(ctx, query, email))
+	if err == sql.ErrNoRows && replica {
+		user, err = scanUser(r.db.QueryRowContext(ctx, query, email))
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *PostgresUserRepository) UpdateUserRole(ctx context.Context, id uuid.UUID, role domain.UserRole) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
 
+	query := `UPDATE users
+        SET role = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $2
+        RETURNING id, name, date_of_birth, email, password, created_at, updated_at, role, status`
+
+	result := &domain.User{}
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		before, err := scanUser(tx.QueryRowContext(ctx, selectUserQuery+` WHERE id = $1 FOR UPDATE`, id))
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, query, role, id).Scan(
+			&result.ID,
+			&result.Name,
+			&result.DateOfBirth,
+			&result.Email,
+			&result.Password,
+			&result.CreatedAt,
+			&result.UpdatedAt,
+			&result.Role,
+			&result.Status,
+		)
+		if err != nil {
+			return err
+		}
+
+		if before.Role == result.Role {
+			return nil
+		}
+		payload := userEventPayload(result)
+		payload.PreviousRole = before.Role
+		return insertOutboxEvent(ctx, tx, domain.EventUserRoleChanged, payload)
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error updating user role: %w", err)
+	}
+	return result, nil
+}
+
+const selectUserQuery = `SELECT id, name, date_of_birth, email, password, created_at, updated_at, role, status
+              FROM users`
+
+func scanUser(row rowScanner) (*domain.User, error) {
 	user := &domain.User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.Name,
 		&user.DateOfBirth,
@@ -139,14 +357,27 @@ func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email strin
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.Role,
+		&user.Status,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
 		return nil, err
 	}
-
 	return user, nil
 }
+
+func changedUserFields(before, after *domain.User) []string {
+	var changed []string
+	if before.Name != after.Name {
+		changed = append(changed, "name")
+	}
+	if before.DateOfBirth != after.DateOfBirth {
+		changed = append(changed, "date_of_birth")
+	}
+	if before.Email != after.Email {
+		changed = append(changed, "email")
+	}
+	if before.Password != after.Password {
+		changed = append(changed, "password")
+	}
+	return changed
+}
@@ -0,0 +1,484 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/postgres"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Statements prepared on every connection the repository uses
+const (
+	stmtInsertUser        = "user_insert"
+	stmtGetUserByID       = "user_get_by_id"
+	stmtGetUserByEmail    = "user_get_by_email"
+	stmtGetUsersByIDs     = "user_get_by_ids"
+	stmtLockUser          = "user_lock"
+	stmtUpdateUser        = "user_update"
+	stmtUpdateUserRole    = "user_update_role"
+	stmtDeleteUser        = "user_delete"
+	stmtInsertOutboxEvent = "user_insert_outbox_event"
+)
+
+const returningUserColumns = `RETURNING id, name, date_of_birth, email, password, created_at, updated_at, role, status`
+
+var userStatements = map[string]string{
+	stmtInsertUser: `INSERT INTO users (name, date_of_birth, email, password, role)
+        VALUES ($1, $2, $3, $4, $5)
+        ` + returningUserColumns,
+	stmtGetUserByID:    selectUserQuery + ` WHERE id = $1`,
+	stmtGetUserByEmail: selectUserQuery + ` WHERE email = $1`,
+	stmtGetUsersByIDs:  selectUserQuery + ` WHERE id = ANY($1)`,
+	stmtLockUser:       selectUserQuery + ` WHERE id = $1 FOR UPDATE`,
+	stmtUpdateUser: `UPDATE users
+        SET
+        name = COALESCE(NULLIF($1, ''), name),
+        date_of_birth = COALESCE(NULLIF($2, ''), date_of_birth),
+        email = COALESCE(NULLIF($3, ''), email),
+        password = COALESCE(NULLIF($4, ''), password),
+        updated_at = CURRENT_TIMESTAMP
+        WHERE id = $5
+        ` + returningUserColumns,
+	stmtUpdateUserRole: `UPDATE users
+        SET role = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $2
+        ` + returningUserColumns,
+	stmtDeleteUser:        `DELETE FROM users WHERE id = $1 RETURNING email`,
+	stmtInsertOutboxEvent: insertOutboxEventQuery,
+}
+
+// pgxQuerier is what *pgx.Conn and pgx.Tx have in common
+type pgxQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error)
+}
+
+// PgxUserRepository is PostgresUserRepository on the native pgx API.
+// It borrows connections from the same database/sql pools, opened with the
+// pgx driver, so replica routing and TxManager transactions work unchanged
+type PgxUserRepository struct {
+	db           *sql.DB
+	dbs          *postgres.ReplicaSet
+	queryTimeout time.Duration
+}
+
+func NewPgxUserRepository(dbs *postgres.ReplicaSet, queryTimeout time.Duration) *PgxUserRepository {
+	return &PgxUserRepository{
+		db:           dbs.Primary(),
+		dbs:          dbs,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (r *PgxUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var created *domain.User
+	err := r.inTx(ctx, func(q pgxQuerier) error {
+		var err error
+		created, err = scanPgxUser(q.QueryRow(ctx, stmtInsertUser,
+			user.Name, user.DateOfBirth, user.Email, user.Password, string(user.Role)))
+		if err != nil {
+			return err
+		}
+		return insertPgxOutboxEvent(ctx, q, domain.EventUserRegistered, userEventPayload(created))
+	})
+	if err != nil {
+		return nil, translateUserError(err)
+	}
+	return created, nil
+}
+
+// ImportUsers copies the users in binary format and queues their events in one batch
+func (r *PgxUserRepository) ImportUsers(ctx context.Context, users []domain.User) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	prepareImportedUsers(users)
+
+	rows := make([][]any, len(users))
+	events := &pgx.Batch{}
+	for i := range users {
+		dateOfBirth, err := parseDateOfBirth(users[i].DateOfBirth)
+		if err != nil {
+			return err
+		}
+		rows[i] = importUserValues(&users[i])
+		rows[i][2] = dateOfBirth
+
+		args, err := outboxEventArgs(domain.EventUserRegistered, userEventPayload(&users[i]))
+		if err != nil {
+			return err
+		}
+		events.Queue(stmtInsertOutboxEvent, args...)
+	}
+
+	err := r.inTx(ctx, func(q pgxQuerier) error {
+		if _, err := q.CopyFrom(ctx, pgx.Identifier{"users"}, importUserColumns, pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+		return q.SendBatch(ctx, events).Close()
+	})
+	if err != nil {
+		return translateUserError(err)
+	}
+	return nil
+}
+
+// A replica that has not seen the user yet is double-checked on the primary,
+// so a user is found right after registration
+func (r *PgxUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	user, err := r.getUser(ctx, stmtGetUserByID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *PgxUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	user, err := r.getUser(ctx, stmtGetUserByEmail, email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUsersByIDs returns the users found, in no particular order
+func (r *PgxUserRepository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	users := make([]domain.User, 0, len(ids))
+	db, _ := r.reader(ctx)
+	err := r.withConn(ctx, db, func(conn *pgx.Conn) error {
+		rows, err := conn.Query(ctx, stmtGetUsersByIDs, ids)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user, err := scanPgxUser(rows)
+			if err != nil {
+				return err
+			}
+			users = append(users, *user)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *PgxUserRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.inTx(ctx, func(q pgxQuerier) error {
+		var email string
+		err := q.QueryRow(ctx, stmtDeleteUser, id).Scan(&email)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return insertPgxOutboxEvent(ctx, q, domain.EventUserDeleted, domain.UserEventPayload{
+			ID:    id,
+			Email: email,
+		})
+	})
+}
+
+func (r *PgxUserRepository) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var result *domain.User
+	err := r.inTx(ctx, func(q pgxQuerier) error {
+		// Locked so the event describes exactly this change
+		before, err := scanPgxUser(q.QueryRow(ctx, stmtLockUser, user.ID))
+		if err != nil {
+			return err
+		}
+
+		result, err = scanPgxUser(q.QueryRow(ctx, stmtUpdateUser,
+			user.Name, user.DateOfBirth, user.Email, user.Password, user.ID))
+		if err != nil {
+			return err
+		}
+
+		changed := changedUserFields(before, result)
+		if len(changed) == 0 {
+			return nil
+		}
+		payload := userEventPayload(result)
+		payload.ChangedFields = changed
+		if before.Email != result.Email {
+			payload.PreviousEmail = before.Email
+		}
+		return insertPgxOutboxEvent(ctx, q, domain.EventUserUpdated, payload)
+	})
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		if pgErrorCode(err) == "23505" {
+			return nil, domain.ErrEmailAlreadyExists
+		}
+		return nil, fmt.Errorf("Error updating user: %w", err)
+	}
+	return result, nil
+}
+
+func (r *PgxUserRepository) UpdateUserRole(ctx context.Context, id uuid.UUID, role domain.UserRole) (*domain.User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var result *domain.User
+	err := r.inTx(ctx, func(q pgxQuerier) error {
+		before, err := scanPgxUser(q.QueryRow(ctx, stmtLockUser, id))
+		if err != nil {
+			return err
+		}
+
+		result, err = scanPgxUser(q.QueryRow(ctx, stmtUpdateUserRole, string(role), id))
+		if err != nil {
+			return err
+		}
+
+		if before.Role == result.Role {
+			return nil
+		}
+		payload := userEventPayload(result)
+		payload.PreviousRole = before.Role
+		return insertPgxOutboxEvent(ctx, q, domain.EventUserRoleChanged, payload)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error updating user role: %w", err)
+	}
+	return result, nil
+}
+
+// SearchUsers sends the count and the page in one batch, a single round trip
+func (r *PgxUserRepository) SearchUsers(ctx context.Context, filter domain.UserSearchFilter) ([]domain.UserSearchHit, int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	countQuery, countArgs, query, args := searchUsersQueries(filter)
+
+	batch := &pgx.Batch{}
+	batch.Queue(countQuery, countArgs...)
+	batch.Queue(query, args...)
+
+	var total int
+	hits := []domain.UserSearchHit{}
+	db, _ := r.reader(ctx)
+	err := r.withConn(ctx, db, func(conn *pgx.Conn) error {
+		results := conn.SendBatch(ctx, batch)
+		defer results.Close()
+
+		if err := results.QueryRow().Scan(&total); err != nil {
+			return err
+		}
+
+		rows, err := results.Query()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var hit domain.UserSearchHit
+			var dateOfBirth time.Time
+			err := rows.Scan(
+				&hit.User.ID,
+				&hit.User.Name,
+				&dateOfBirth,
+				&hit.User.Email,
+				&hit.User.Password,
+				&hit.User.CreatedAt,
+				&hit.User.UpdatedAt,
+				&hit.User.Role,
+				&hit.User.Status,
+				&hit.Rank,
+			)
+			if err != nil {
+				return err
+			}
+			hit.User.DateOfBirth = formatDateOfBirth(dateOfBirth)
+			hits = append(hits, hit)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+func (r *PgxUserRepository) getUser(ctx context.Context, stmt string, arg any) (*domain.User, error) {
+	get := func(db *sql.DB) (user *domain.User, err error) {
+		err = r.withConn(ctx, db, func(conn *pgx.Conn) error {
+			user, err = scanPgxUser(conn.QueryRow(ctx, stmt, arg))
+			return err
+		})
+		return user, err
+	}
+
+	db, replica := r.reader(ctx)
+	user, err := get(db)
+	if errors.Is(err, pgx.ErrNoRows) && replica {
+		user, err = get(r.db)
+	}
+	return user, err
+}
+
+// reader picks the pool to read from. Inside a transaction withConn
+// ignores it and reads through the transaction
+func (r *PgxUserRepository) reader(ctx context.Context) (_ *sql.DB, replica bool) {
+	if txFromContext(ctx) != nil {
+		return r.db, false
+	}
+	db := r.dbs.Reader(ctx)
+	return db, db != r.db
+}
+
+// inTx runs fn in a transaction, or in a savepoint of the transaction in ctx
+func (r *PgxUserRepository) inTx(ctx context.Context, fn func(q pgxQuerier) error) error {
+	if state := txFromContext(ctx); state != nil {
+		return withSavepoint(ctx, state, func(ctx context.Context) error {
+			return r.withConn(ctx, r.db, func(conn *pgx.Conn) error {
+				return fn(conn)
+			})
+		})
+	}
+	return r.withConn(ctx, r.db, func(conn *pgx.Conn) error {
+		return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			return fn(tx)
+		})
+	})
+}
+
+// withConn runs fn on the pgx connection of the transaction in ctx,
+// or of a connection taken from db. Statements are prepared once per connection
+func (r *PgxUserRepository) withConn(ctx context.Context, db *sql.DB, fn func(conn *pgx.Conn) error) error {
+	var sqlConn *sql.Conn
+	if state := txFromContext(ctx); state != nil {
+		sqlConn = state.conn
+	} else {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		sqlConn = conn
+	}
+
+	return sqlConn.Raw(func(driverConn any) error {
+		// otelsql wraps the driver connection
+		if wrapped, ok := driverConn.(interface{ Raw() driver.Conn }); ok {
+			driverConn = wrapped.Raw()
+		}
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("pgx user repository needs the pgx driver, got %T", driverConn)
+		}
+
+		conn := stdConn.Conn()
+		for name, query := range userStatements {
+			if _, err := conn.Prepare(ctx, name, query); err != nil {
+				return fmt.Errorf("prepare %s: %w", name, err)
+			}
+		}
+		return fn(conn)
+	})
+}
+
+func insertPgxOutboxEvent(ctx context.Context, q pgxQuerier, eventType domain.EventType, payload domain.UserEventPayload) error {
+	args, err := outboxEventArgs(eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.Exec(ctx, stmtInsertOutboxEvent, args...); err != nil {
+		return fmt.Errorf("insert %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+func scanPgxUser(row pgx.Row) (*domain.User, error) {
+	user := &domain.User{}
+	var dateOfBirth time.Time
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&dateOfBirth,
+		&user.Email,
+		&user.Password,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Role,
+		&user.Status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	user.DateOfBirth = formatDateOfBirth(dateOfBirth)
+	return user, nil
+}
+
+// formatDateOfBirth renders a DATE the way database/sql does for lib/pq,
+// so responses and cached users do not depend on the driver
+func formatDateOfBirth(date time.Time) string {
+	return date.Format(time.RFC3339Nano)
+}
+
+// parseDateOfBirth accepts both the API format and the one users are read with
+func parseDateOfBirth(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid date of birth %q", domain.ErrValidation, value)
+	}
+	return date, nil
+}
+
+var _ ports.UserRepository = (*PgxUserRepository)(nil)
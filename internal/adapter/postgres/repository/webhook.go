@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	webhookSubscriptionColumns = `id, url, events, secret, created_at`
+	webhookDeliveryColumns     = `id, subscription_id, event_id, event_type, aggregate_id, occurred_at, payload,
+        status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+)
+
+type PostgresWebhookRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewWebhookRepository(db *sql.DB, queryTimeout time.Duration) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (r *PostgresWebhookRepository) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `INSERT INTO webhook_subscriptions (url, events, secret)
+    VALUES ($1, $2, $3)
+    RETURNING ` + webhookSubscriptionColumns
+
+	return scanWebhookSubscription(conn(ctx, r.db).QueryRowContext(ctx, query,
+		subscription.URL, pq.Array(eventTypeStrings(subscription.Events)), subscription.Secret))
+}
+
+func (r *PostgresWebhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+
+	return scanWebhookSubscription(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresWebhookRepository) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY created_at`
+
+	return r.listSubscriptions(ctx, query)
+}
+
+func (r *PostgresWebhookRepository) ListSubscriptionsForEvent(ctx context.Context, eventType domain.EventType) ([]domain.WebhookSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions
+        WHERE $1 = ANY(events)
+        ORDER BY created_at`
+
+	return r.listSubscriptions(ctx, query, string(eventType))
+}
+
+func (r *PostgresWebhookRepository) listSubscriptions(ctx context.Context, query string, args ...interface{}) ([]domain.WebhookSubscription, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []domain.WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// Deliveries of the subscription are deleted with it
+func (r *PostgresWebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepository) EnqueueDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, aggregate_id, occurred_at, payload)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, delivery := range deliveries {
+			_, err := tx.ExecContext(ctx, query,
+				delivery.SubscriptionID,
+				delivery.Event.ID,
+				string(delivery.Event.Type),
+				delivery.Event.AggregateID,
+				delivery.Event.OccurredAt,
+				[]byte(delivery.Event.Payload),
+			)
+			if err != nil {
+				return fmt.Errorf("enqueue webhook delivery: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDueDeliveries moves next_attempt_at past the lease, so a worker that
+// dies mid-delivery only delays the delivery. SKIP LOCKED lets replicas
+// claim in parallel without picking the same rows
+func (r *PostgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries
+        SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+        WHERE id IN (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + webhookDeliveryColumns
+
+	return r.listDeliveries(ctx, query, limit, lease.Milliseconds())
+}
+
+func (r *PostgresWebhookRepository) SaveAttempt(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries
+        SET status = $2, attempts = $3, next_attempt_at = $4,
+        last_status_code = $5, last_error = $6, delivered_at = $7
+        WHERE id = $1`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query,
+		delivery.ID,
+		string(delivery.Status),
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*domain.WebhookDelivery, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	return scanWebhookDelivery(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresWebhookRepository) ListDeliveries(ctx context.Context, filter domain.DeliveryFilter) ([]domain.WebhookDelivery, int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	conditions := []string{"subscription_id = $1"}
+	args := []interface{}{filter.SubscriptionID}
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM webhook_deliveries%s
+        ORDER BY created_at DESC
+        LIMIT $%d OFFSET $%d`, webhookDeliveryColumns, where, len(args)-1, len(args))
+
+	deliveries, err := r.listDeliveries(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+func (r *PostgresWebhookRepository) listDeliveries(ctx context.Context, query string, args ...interface{}) ([]domain.WebhookDelivery, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func scanWebhookSubscription(row rowScanner) (*domain.WebhookSubscription, error) {
+	var (
+		subscription domain.WebhookSubscription
+		events       []string
+	)
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.URL,
+		pq.Array(&events),
+		&subscription.Secret,
+		&subscription.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		subscription.Events = append(subscription.Events, domain.EventType(event))
+	}
+	return &subscription, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*domain.WebhookDelivery, error) {
+	var (
+		delivery          domain.WebhookDelivery
+		eventType, status string
+		payload           []byte
+		deliveredAt       sql.NullTime
+	)
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.Event.ID,
+		&eventType,
+		&delivery.Event.AggregateID,
+		&delivery.Event.OccurredAt,
+		&payload,
+		&status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Event.Type = domain.EventType(eventType)
+	delivery.Event.Payload = payload
+	delivery.Status = domain.DeliveryStatus(status)
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+func eventTypeStrings(events []domain.EventType) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, string(event))
+	}
+	return result
+}
+
+var _ ports.WebhookRepository = (*PostgresWebhookRepository)(nil)
@@ -1,72 +1,154 @@
 package prometheus
 
 import (
-	"fmt"
+	"database/sql"
 	"time"
+
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
 
-	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
+// PrometheusAdapter keeps every metric of the service by name.
+// Label values are taken from the labels map in the order declared here,
+// app_name is attached to every series as a constant label
 type PrometheusAdapter struct {
-	httpRequestsTotal   *prometheus.CounterVec
-	httpRequestDuration *prometheus.HistogramVec
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+	labels     map[string][]string
+	appLabel   prometheus.Labels
 }
 
-func NewPrometheusAdapter() ports.MetricsPort {
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+
+func NewPrometheusAdapter(appName string) *PrometheusAdapter {
 	adapter := &PrometheusAdapter{
-		httpRequestsTotal: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "http_requests_total",
-				Help: "Total number of HTTP requests",
-			},
-			[]string{"path", "method", "status", "app_name"},
-		),
-		httpRequestDuration: prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "api_request_duration_seconds",
-				Help:    "Duration API requests",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"path", "method", "status", "app_name"},
-		),
+		counters:   make(map[string]*prometheus.CounterVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+		labels:     make(map[string][]string),
+		appLabel:   prometheus.Labels{"app_name": appName},
 	}
 
-	prometheus.MustRegister(adapter.httpRequestsTotal)
-	prometheus.MustRegister(adapter.httpRequestDuration)
+	// HTTP, labelled by route template so IDs do not create new series
+	adapter.counter(ports.MetricHTTPRequests, "Total number of HTTP requests",
+		"path", "method", "status")
+	adapter.histogram(ports.MetricHTTPRequestDuration, "Duration API requests", prometheus.DefBuckets,
+		"path", "method", "status")
+	adapter.histogram(ports.MetricHTTPRequestSize, "Size of HTTP request bodies in bytes", sizeBuckets,
+		"path", "method")
+	adapter.histogram(ports.MetricHTTPResponseSize, "Size of HTTP response bodies in bytes", sizeBuckets,
+		"path", "method", "status")
+	adapter.gauge(ports.MetricHTTPInFlight, "Number of HTTP requests being served",
+		"path", "method")
+
+	// gRPC, labelled by full method name
+	adapter.counter(ports.MetricGRPCRequests, "Total number of gRPC requests",
+		"method", "code")
+	adapter.histogram(ports.MetricGRPCRequestDuration, "Duration of gRPC requests", prometheus.DefBuckets,
+		"method", "code")
+
+	// Business
+	adapter.counter(ports.MetricRegistrations, "User registrations by outcome", "outcome")
+	adapter.counter(ports.MetricLogins, "Logins by outcome", "outcome")
+	adapter.counter(ports.MetricDeletions, "User deletions by outcome", "outcome")
+
+	// Dependencies
+	adapter.counter(ports.MetricCacheRequests, "Cache lookups by cache and result: hit, miss or error",
+		"cache", "result")
+	adapter.gauge(ports.MetricCircuitBreakerState, "Circuit breaker state: 0 closed, 1 half-open, 2 open",
+		"name")
+	adapter.gauge(ports.MetricDBReplicaHealthy, "Whether reads are routed to the replica: 1 healthy, 0 skipped",
+		"replica")
+
+	// Outbox
+	adapter.counter(ports.MetricOutboxPublished, "Outbox events published by type and outcome",
+		"type", "outcome")
+	adapter.gauge(ports.MetricOutboxPending, "Outbox events waiting to be published")
+	adapter.counter(ports.MetricEventsPublished, "Events sent to the broker by transport, type and outcome",
+		"transport", "type", "outcome")
+	adapter.histogram(ports.MetricEventPublishDuration, "Time until the broker confirmed an event", prometheus.DefBuckets,
+		"transport")
+	adapter.counter(ports.MetricWebhookDeliveries, "Webhook delivery attempts by outcome: success, retry or dead",
+		"outcome")
 
-	// ебаная строчка
-	adapter.httpRequestsTotal.WithLabelValues("/health", "GET", "200", "user_microservice").Add(0)
 	return adapter
 }
 
+// RegisterDBStats exports connection pool stats of db
+func (p *PrometheusAdapter) RegisterDBStats(db *sql.DB, name string) {
+	prometheus.WrapRegistererWith(p.appLabel, prometheus.DefaultRegisterer).
+		MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
 func (p *PrometheusAdapter) IncrementCounter(name string, labels map[string]string) {
-	p.httpRequestsTotal.WithLabelValues(
-		labels["path"],
-		labels["method"],
-		labels["status"],
-		"user_microservice",
-	).Inc()
+	if counter, ok := p.counters[name]; ok {
+		counter.WithLabelValues(p.labelValues(name, labels)...).Inc()
+	}
 }
 
 func (p *PrometheusAdapter) RecordDuration(name string, duration time.Duration, labels map[string]string) {
-	p.httpRequestDuration.WithLabelValues(
-		labels["path"],
-		labels["method"],
-		labels["status"],
-		"user_microservice",
-	).Observe(duration.Seconds())
+	p.ObserveValue(name, duration.Seconds(), labels)
+}
+
+func (p *PrometheusAdapter) ObserveValue(name string, value float64, labels map[string]string) {
+	if histogram, ok := p.histograms[name]; ok {
+		histogram.WithLabelValues(p.labelValues(name, labels)...).Observe(value)
+	}
 }
 
-func (p *PrometheusAdapter) RecordMetrics(c *gin.Context, start time.Time) {
-	status := fmt.Sprintf("%d", c.Writer.Status())
-	labels := map[string]string{
-		"path":   c.Request.URL.Path,
-		"method": c.Request.Method,
-		"status": status,
+func (p *PrometheusAdapter) SetGauge(name string, value float64, labels map[string]string) {
+	if gauge, ok := p.gauges[name]; ok {
+		gauge.WithLabelValues(p.labelValues(name, labels)...).Set(value)
 	}
+}
+
+func (p *PrometheusAdapter) AddGauge(name string, delta float64, labels map[string]string) {
+	if gauge, ok := p.gauges[name]; ok {
+		gauge.WithLabelValues(p.labelValues(name, labels)...).Add(delta)
+	}
+}
+
+func (p *PrometheusAdapter) counter(name, help string, labels ...string) {
+	p.counters[name] = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        name,
+		Help:        help,
+		ConstLabels: p.appLabel,
+	}, labels)
+	p.labels[name] = labels
+	prometheus.MustRegister(p.counters[name])
+}
 
-	p.IncrementCounter("http_requests_total", labels)
-	p.RecordDuration("api_request_duration_seconds", time.Since(start), labels)
+func (p *PrometheusAdapter) histogram(name, help string, buckets []float64, labels ...string) {
+	p.histograms[name] = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        name,
+		Help:        help,
+		Buckets:     buckets,
+		ConstLabels: p.appLabel,
+	}, labels)
+	p.labels[name] = labels
+	prometheus.MustRegister(p.histograms[name])
 }
+
+func (p *PrometheusAdapter) gauge(name, help string, labels ...string) {
+	p.gauges[name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        name,
+		Help:        help,
+		ConstLabels: p.appLabel,
+	}, labels)
+	p.labels[name] = labels
+	prometheus.MustRegister(p.gauges[name])
+}
+
+func (p *PrometheusAdapter) labelValues(name string, labels map[string]string) []string {
+	names := p.labels[name]
+	values := make([]string, len(names))
+	for i, label := range names {
+		values[i] = labels[label]
+	}
+	return values
+}
+
+var _ ports.MetricsPort = (*PrometheusAdapter)(nil)
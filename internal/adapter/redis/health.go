@@ -0,0 +1,34 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type HealthChecker struct {
+	client *redis.Client
+}
+
+func NewHealthChecker(client *redis.Client) ports.HealthChecker {
+	return &HealthChecker{
+		client: client,
+	}
+}
+
+func (h *HealthChecker) Name() string {
+	return "redis"
+}
+
+// The service keeps running without cache, so Redis does not affect readiness
+func (h *HealthChecker) Critical() bool {
+	return false
+}
+
+func (h *HealthChecker) Check(ctx context.Context) error {
+	return h.client.Ping(ctx).Err()
+}
+
+var _ ports.HealthChecker = (*HealthChecker)(nil)
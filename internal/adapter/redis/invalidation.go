@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Invalidator broadcasts cache invalidations between instances over Redis pub/sub.
+// Publishing shares the breaker of the cache, so writes do not wait on a Redis
+// that is known to be down
+type Invalidator struct {
+	client     *redis.Client
+	breaker    Breaker
+	channel    string
+	instanceID string
+}
+
+type invalidationMessage struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+func NewInvalidator(client *redis.Client, breaker Breaker, channel, instanceID string) *Invalidator {
+	return &Invalidator{
+		client:     client,
+		breaker:    breaker,
+		channel:    channel,
+		instanceID: instanceID,
+	}
+}
+
+func (i *Invalidator) Publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	message, err := json.Marshal(invalidationMessage{
+		Origin: i.instanceID,
+		Keys:   keys,
+	})
+	if err != nil {
+		return err
+	}
+
+	return i.breaker.Execute(func() error {
+		return i.client.Publish(ctx, i.channel, message).Err()
+	})
+}
+
+// Subscribe skips messages published by this instance, its L1 is already clean
+func (i *Invalidator) Subscribe(ctx context.Context, handler func(keys []string)) error {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+
+			var message invalidationMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+				continue
+			}
+			if message.Origin == i.instanceID {
+				continue
+			}
+			handler(message.Keys)
+		}
+	}
+}
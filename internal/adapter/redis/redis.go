@@ -3,41 +3,145 @@ package redis
 import (
 	"context"
 	"time"
+
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// Tag index: a set of keys per tag, stored under tag:<name>
+const tagKeyPrefix = "tag:"
+
+// Breaker fails calls fast while Redis is known to be down,
+// used by the adapters that talk to Redis outside the cache
+type Breaker interface {
+	Execute(fn func() error) error
+}
+
 type RedisAdapter struct {
 	client *redis.Client
-	ctx    context.Context
 }
 
-func NewRedisAdapter(client *redis.Client) ports.CachePort {
+func NewRedisAdapter(client *redis.Client) *RedisAdapter {
 	return &RedisAdapter{
 		client: client,
-		ctx:    context.Background(),
 	}
 }
 
-func (r *RedisAdapter) Get(key string) ([]byte, error) {
-	result, err := r.client.Get(r.ctx, key).Result()
+func (r *RedisAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	result, err := r.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
+		return nil, ports.ErrCacheMiss
+	}
+	if err != nil {
 		return nil, err
 	}
+
+	return result, nil
+}
+
+func (r *RedisAdapter) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return r.MSet(ctx, ports.CacheItem{
+		Key:   key,
+		Value: value,
+		TTL:   ttl,
+		Tags:  tags,
+	})
+}
+
+func (r *RedisAdapter) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *RedisAdapter) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	found := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return found, nil
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	return []byte(result), nil
+	for i, value := range values {
+		if str, ok := value.(string); ok {
+			found[keys[i]] = []byte(str)
+		}
+	}
+	return found, nil
+}
+
+// MSet writes all items and their tag index entries in one pipeline
+func (r *RedisAdapter) MSet(ctx context.Context, items ...ports.CacheItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, item := range items {
+			pipe.Set(ctx, item.Key, item.Value, item.TTL)
+
+			for _, tag := range item.Tags {
+				tagKey := tagKeyPrefix + tag
+				pipe.SAdd(ctx, tagKey, item.Key)
+				// The index must live at least as long as its longest member
+				if item.TTL > 0 {
+					pipe.ExpireNX(ctx, tagKey, item.TTL)
+					pipe.ExpireGT(ctx, tagKey, item.TTL)
+				} else {
+					pipe.Persist(ctx, tagKey)
+				}
+			}
+		}
+		return nil
+	})
+	return err
 }
 
-func (r *RedisAdapter) Set(key string, value []byte, ttl time.Duration) error {
-	return r.client.Set(r.ctx, key, value, ttl).Err()
+func (r *RedisAdapter) DeleteMany(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *RedisAdapter) InvalidateTags(ctx context.Context, tags ...string) error {
+	keys, err := r.TagKeys(ctx, tags...)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		keys = append(keys, tagKeyPrefix+tag)
+	}
+	return r.DeleteMany(ctx, keys...)
 }
 
-func (r *RedisAdapter) Delete(key string) error {
-	return r.client.Del(r.ctx, key).Err()
+func (r *RedisAdapter) TagKeys(ctx context.Context, tags ...string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	cmds := make([]*redis.StringSliceCmd, 0, len(tags))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			cmds = append(cmds, pipe.SMembers(ctx, tagKeyPrefix+tag))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, cmd := range cmds {
+		keys = append(keys, cmd.Val()...)
+	}
+	return keys, nil
 }
 
-var _ ports.CachePort = (*RedisAdapter)(nil)
+var (
+	_ ports.CachePort        = (*RedisAdapter)(nil)
+	_ ports.CacheTagResolver = (*RedisAdapter)(nil)
+)
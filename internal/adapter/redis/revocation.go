@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const revokedTokenKeyPrefix = "revoked_token:"
+
+// RevocationStore keeps revoked token IDs in Redis until the tokens expire.
+// It talks to Redis directly: unlike the cache, losing a write is not harmless.
+// Calls share the breaker of the cache, so an outage costs no timeout per request
+type RevocationStore struct {
+	client   *redis.Client
+	breaker  Breaker
+	failOpen bool
+}
+
+// failOpen only changes how readiness reports an outage,
+// the auth service decides whether unchecked tokens pass
+func NewRevocationStore(client *redis.Client, breaker Breaker, failOpen bool) *RevocationStore {
+	return &RevocationStore{
+		client:   client,
+		breaker:  breaker,
+		failOpen: failOpen,
+	}
+}
+
+func (s *RevocationStore) Revoke(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// Already expired, nothing to remember
+		return nil
+	}
+	return s.breaker.Execute(func() error {
+		return s.client.Set(ctx, revokedTokenKeyPrefix+tokenID.String(), 1, ttl).Err()
+	})
+}
+
+func (s *RevocationStore) IsRevoked(ctx context.Context, tokenID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.breaker.Execute(func() error {
+		var err error
+		count, err = s.client.Exists(ctx, revokedTokenKeyPrefix+tokenID.String()).Result()
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Health checker, tokens are rejected while Redis is down unless the service fails open
+
+func (s *RevocationStore) Name() string {
+	return "token_revocation"
+}
+
+func (s *RevocationStore) Critical() bool {
+	return !s.failOpen
+}
+
+func (s *RevocationStore) Check(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RevocationStore) HealthDetails() map[string]string {
+	mode := "fail_closed"
+	if s.failOpen {
+		mode = "fail_open"
+	}
+	return map[string]string{
+		"mode": mode,
+	}
+}
+
+var (
+	_ ports.TokenRevocationStore = (*RevocationStore)(nil)
+	_ ports.HealthChecker        = (*RevocationStore)(nil)
+	_ ports.HealthDetailer       = (*RevocationStore)(nil)
+)
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/events"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Headers of every delivery. Receivers recompute the signature over
+// "<timestamp>.<body>" with their secret and reject old timestamps
+const (
+	HeaderDelivery  = "X-Webike-Delivery"
+	HeaderEvent     = "X-Webike-Event"
+	HeaderTimestamp = "X-Webike-Timestamp"
+	HeaderSignature = "X-Webike-Signature"
+)
+
+// Deliveries carry domain.WebhookEventPayload, not the user event payload
+const WebhookEventSchemaID = "urn:webike:schema:user-webhook:v1"
+
+// Sender posts deliveries as CloudEvents signed with HMAC-SHA256
+type Sender struct {
+	client *http.Client
+	source string
+}
+
+func NewSender(timeout time.Duration, source string) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			// A redirect is a failed delivery, the subscriber has to fix its URL
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		source: source,
+	}
+}
+
+func (s *Sender) Send(ctx context.Context, subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) (int, error) {
+	envelope := events.NewCloudEvent(s.source, delivery.Event)
+	envelope.DataSchema = WebhookEventSchemaID
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return 0, fmt.Errorf("encode webhook body: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(subscription.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ ports.WebhookSender = (*Sender)(nil)
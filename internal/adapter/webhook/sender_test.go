@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/adapter/events"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+// Vectors computed with openssl dgst -sha256 -hmac, receivers in other languages must agree
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "body",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			body:      `{"id":"1"}`,
+			want:      "11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5",
+		},
+		{
+			name:      "empty secret and body",
+			timestamp: "1700000000",
+			want:      "c1da1b6c6b8e9da7f4bbb90f7cab0820f271ad19ccbf80c88479c4e14f37d1c6",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	subscription := &domain.WebhookSubscription{ID: uuid.New(), URL: server.URL, Secret: "whsec_test"}
+	userID := uuid.New()
+	payload, _ := json.Marshal(domain.WebhookEventPayload{UserID: userID})
+	delivery := &domain.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: subscription.ID,
+		Event: domain.Event{
+			ID:          uuid.New(),
+			Type:        domain.EventUserRegistered,
+			AggregateID: userID,
+			OccurredAt:  time.Now(),
+			Payload:     payload,
+		},
+	}
+
+	before := time.Now().Unix()
+	status, err := NewSender(time.Second, "webike-user").Send(context.Background(), subscription, delivery)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", status, http.StatusAccepted)
+	}
+
+	wantHeaders := map[string]string{
+		"Content-Type": "application/cloudevents+json",
+		HeaderDelivery: delivery.ID.String(),
+		HeaderEvent:    string(domain.EventUserRegistered),
+	}
+	for name, want := range wantHeaders {
+		if got := header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	timestamp := header.Get(HeaderTimestamp)
+	if sent, err := strconv.ParseInt(timestamp, 10, 64); err != nil || sent < before || sent > time.Now().Unix() {
+		t.Errorf("%s = %q, want the unix time of sending", HeaderTimestamp, timestamp)
+	}
+	if got, want := header.Get(HeaderSignature), "sha256="+Sign(subscription.Secret, timestamp, body); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+
+	var envelope events.CloudEvent
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if envelope.ID != delivery.Event.ID.String() || envelope.DataSchema != WebhookEventSchemaID || string(envelope.Data) != string(payload) {
+		t.Errorf("envelope = %+v, want event %s with schema %s and data %s", envelope, delivery.Event.ID, WebhookEventSchemaID, payload)
+	}
+}
+
+func TestSender_SendRejectsNon2xx(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{name: "redirect", status: http.StatusFound},
+		{name: "client error", status: http.StatusGone},
+		{name: "server error", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			status, err := NewSender(time.Second, "webike-user").Send(context.Background(),
+				&domain.WebhookSubscription{URL: server.URL, Secret: "whsec_test"},
+				&domain.WebhookDelivery{ID: uuid.New(), Event: domain.Event{ID: uuid.New(), Payload: []byte(`{}`)}})
+			if err == nil {
+				t.Error("Send succeeded, want an error")
+			}
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
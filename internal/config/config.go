@@ -2,18 +2,34 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// APP_ENV of production deployments, "production" is accepted as well
+const EnvProd = "prod"
+
 type (
 	Container struct {
-		App         *App
-		Token       *Token
-		DB          *DB
-		HTTP        *HTTP
-		Redis       *Redis
-		BikeService *BikeService
+		App           *App
+		Token         *Token
+		DB            *DB
+		HTTP          *HTTP
+		GRPC          *GRPC
+		Redis         *Redis
+		BikeService   *BikeService
+		Health        *Health
+		Cache         *Cache
+		Tracing       *Tracing
+		Logging       *Logging
+		Introspection *Introspection
+		Outbox        *Outbox
+		Events        *Events
+		Webhooks      *Webhooks
+		Deletion      *Deletion
 	}
 
 	App struct {
@@ -24,6 +40,12 @@ type (
 	Token struct {
 		Secret   string
 		Duration string
+		// Lifetime of client credentials tokens issued to other services
+		ServiceDuration time.Duration
+		// Accept tokens whose revocation cannot be checked while Redis is down
+		RevocationFailOpen bool
+		// How long the active state of a service client is reused
+		ClientCacheTTL time.Duration
 	}
 
 	DB struct {
@@ -32,27 +54,177 @@ type (
 		User     string
 		Password string
 		Name     string
+		// pq for lib/pq, pgx for jackc/pgx with the native user repository
+		Driver string
+		// disable, require, verify-ca or verify-full
+		SSLMode     string
+		SSLRootCert string
+		// Client certificate, for servers that authenticate by certificate
+		SSLCert string
+		SSLKey  string
+
+		MaxOpenConns    int
+		MaxIdleConns    int
+		ConnMaxLifetime time.Duration
+		ConnMaxIdleTime time.Duration
+		// Server side limit for a single statement, zero keeps the server default
+		StatementTimeout time.Duration
+		// Deadline of repository calls made with a context that has none
+		QueryTimeout time.Duration
+
+		// Read replicas as host:port, same credentials as the primary
+		Replicas             []string
+		ReplicaCheckInterval time.Duration
+		ReplicaCheckTimeout  time.Duration
+		ReplicaMaxLag        time.Duration
+		// Reruns of a transaction after a serialization failure or deadlock
+		TxMaxRetries     int
+		TxRetryBaseDelay time.Duration
 	}
 
 	HTTP struct {
-		Env            string
-		Port           string
-		AllowedOrigins string
-		URL            string
+		Env             string
+		Port            string
+		AllowedOrigins  string
+		URL             string
+		ShutdownTimeout time.Duration
+		DrainDelay      time.Duration
+	}
+
+	GRPC struct {
+		Port string
+		// Server reflection for grpcurl and similar tools, off unless asked for
+		// since it lists every method to anyone who can connect
+		Reflection bool
 	}
 
 	Redis struct {
 		Address  string
 		Password string
+		Timeout  time.Duration
+		// Circuit breaker around the cache
+		BreakerThreshold     int
+		BreakerOpenTimeout   time.Duration
+		BreakerProbeInterval time.Duration
 	}
 
 	BikeService struct {
-		URL string
+		URL        string
+		HealthPath string
+		// Returns the bikes of the token owner
+		BikesPath string
+		// Deletes the bikes of a user, {id} is replaced with the user ID
+		UserBikesPath string
+		// Sent with calls made on behalf of this service
+		Token string
+		// Per attempt
+		Timeout            time.Duration
+		MaxRetries         int
+		RetryBaseDelay     time.Duration
+		BreakerThreshold   int
+		BreakerOpenTimeout time.Duration
+		CacheTTL           time.Duration
+	}
+
+	Health struct {
+		CheckTimeout time.Duration
+	}
+
+	Cache struct {
+		// In-process L1 cache in front of Redis, disabled when size is 0
+		L1Size              int
+		L1TTL               time.Duration
+		InvalidationChannel string
+		NegativeTTL         time.Duration
+		EarlyRefreshBeta    float64
+	}
+
+	Tracing struct {
+		Enabled bool
+		// OTLP gRPC collector address, e.g. tempo:4317
+		Endpoint    string
+		Insecure    bool
+		SampleRatio float64
+	}
+
+	// Static clients allowed to call POST /introspect, client ID to secret.
+	// Service clients with the tokens:introspect scope are accepted as well
+	Introspection struct {
+		Clients map[string]string
+	}
+
+	// Relay of user events written to the outbox table
+	Outbox struct {
+		PollInterval    time.Duration
+		BatchSize       int
+		Retention       time.Duration
+		CleanupInterval time.Duration
+		// Dead-lettered after this many failed attempts
+		MaxAttempts int
+		BaseBackoff time.Duration
+		MaxBackoff  time.Duration
+	}
+
+	// Transport of user events to other services
+	Events struct {
+		// log, nats or kafka
+		Transport string
+		// CloudEvents source attribute
+		Source         string
+		PublishTimeout time.Duration
+
+		NATSURL           string
+		NATSStream        string
+		NATSSubjectPrefix string
+
+		KafkaBrokers []string
+		KafkaTopic   string
+	}
+
+	// Delivery of user events to partner webhooks
+	Webhooks struct {
+		// Dead-lettered after this many failed attempts
+		MaxAttempts  int
+		BaseBackoff  time.Duration
+		MaxBackoff   time.Duration
+		Timeout      time.Duration
+		PollInterval time.Duration
+		BatchSize    int
+		Concurrency  int
+		Lease        time.Duration
+	}
+
+	// Account deletion saga
+	Deletion struct {
+		// Failed bike service calls before the deletion is rolled back
+		MaxAttempts  int
+		BaseBackoff  time.Duration
+		MaxBackoff   time.Duration
+		PollInterval time.Duration
+		BatchSize    int
+		Lease        time.Duration
+		// How long a delete request waits for the saga before the worker takes over
+		AdvanceTimeout time.Duration
+	}
+
+	// PII redaction in logs, always on in production.
+	// The hash key is required then and must be the same on every replica
+	Logging struct {
+		Redact           bool
+		RedactHashKey    string
+		RedactMaskFields []string
+		RedactHashFields []string
+		RedactDropFields []string
 	}
 )
 
 func New() (*Container, error) {
-	if os.Getenv("APP_ENV") != "production" {
+	env := os.Getenv("APP_ENV")
+	if env == "production" {
+		env = EnvProd
+	}
+
+	if env != EnvProd {
 		err := godotenv.Load()
 		if err != nil {
 			return nil, err
@@ -60,13 +232,18 @@ func New() (*Container, error) {
 	}
 
 	app := &App{
-		Name: os.Getenv("APP_NAME"),
-		Env:  os.Getenv("APP_ENV"),
+		Name: getEnv("APP_NAME", "user_microservice"),
+		Env:  env,
 	}
 
 	token := &Token{
 		Secret:   os.Getenv("TOKEN_SECRET"),
 		Duration: os.Getenv("TOKEN_DURATION"),
+
+		ServiceDuration: getEnvDuration("SERVICE_TOKEN_DURATION", 15*time.Minute),
+
+		RevocationFailOpen: getEnvBool("TOKEN_REVOCATION_FAIL_OPEN", false),
+		ClientCacheTTL:     getEnvDuration("TOKEN_CLIENT_CACHE_TTL", 30*time.Second),
 	}
 
 	db := &DB{
@@ -75,30 +252,222 @@ func New() (*Container, error) {
 		User:     os.Getenv("DB_USER"),
 		Password: os.Getenv("DB_PASSWORD"),
 		Name:     os.Getenv("DB_NAME"),
+
+		Driver: getEnv("DB_DRIVER", "pq"),
+
+		SSLMode:     getEnv("DB_SSLMODE", "disable"),
+		SSLRootCert: os.Getenv("DB_SSLROOTCERT"),
+		SSLCert:     os.Getenv("DB_SSLCERT"),
+		SSLKey:      os.Getenv("DB_SSLKEY"),
+
+		MaxOpenConns:     getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:     getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime:  getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime:  getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		StatementTimeout: getEnvDuration("DB_STATEMENT_TIMEOUT", 0),
+		QueryTimeout:     getEnvDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+
+		Replicas:             getEnvList("DB_REPLICAS"),
+		ReplicaCheckInterval: getEnvDuration("DB_REPLICA_CHECK_INTERVAL", 5*time.Second),
+		ReplicaCheckTimeout:  getEnvDuration("DB_REPLICA_CHECK_TIMEOUT", time.Second),
+		ReplicaMaxLag:        getEnvDuration("DB_REPLICA_MAX_LAG", 5*time.Second),
+
+		TxMaxRetries:     getEnvInt("DB_TX_MAX_RETRIES", 3),
+		TxRetryBaseDelay: getEnvDuration("DB_TX_RETRY_BASE_DELAY", 10*time.Millisecond),
 	}
 
 	http := &HTTP{
-		Port:           os.Getenv("HTTP_PORT"),
-		AllowedOrigins: os.Getenv("ALLOWED_ORIGINS"),
-		URL:            os.Getenv("HTTP_URL"),
-		Env:            os.Getenv("APP_ENV"),
+		Port:            os.Getenv("HTTP_PORT"),
+		AllowedOrigins:  os.Getenv("ALLOWED_ORIGINS"),
+		URL:             os.Getenv("HTTP_URL"),
+		Env:             env,
+		ShutdownTimeout: getEnvDuration("HTTP_SHUTDOWN_TIMEOUT", 15*time.Second),
+		DrainDelay:      getEnvDuration("HTTP_DRAIN_DELAY", 5*time.Second),
+	}
+
+	grpc := &GRPC{
+		Port:       getEnv("GRPC_PORT", "50051"),
+		Reflection: getEnvBool("GRPC_REFLECTION", false),
 	}
 
 	redis := &Redis{
-		Address:  os.Getenv("REDIS_ADDRESS"),
-		Password: os.Getenv("REDIS_PASSWORD"),
+		Address:              os.Getenv("REDIS_ADDRESS"),
+		Password:             os.Getenv("REDIS_PASSWORD"),
+		Timeout:              getEnvDuration("REDIS_TIMEOUT", 500*time.Millisecond),
+		BreakerThreshold:     getEnvInt("REDIS_BREAKER_THRESHOLD", 5),
+		BreakerOpenTimeout:   getEnvDuration("REDIS_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		BreakerProbeInterval: getEnvDuration("REDIS_BREAKER_PROBE_INTERVAL", 5*time.Second),
 	}
 
 	bikeService := &BikeService{
-		URL: os.Getenv("BIKE_SERVICE_URL"),
+		URL:        os.Getenv("BIKE_SERVICE_URL"),
+		HealthPath: getEnv("BIKE_SERVICE_HEALTH_PATH", "/healthz"),
+		BikesPath:  getEnv("BIKE_SERVICE_BIKES_PATH", "/bikes/my"),
+
+		UserBikesPath: getEnv("BIKE_SERVICE_USER_BIKES_PATH", "/internal/users/{id}/bikes"),
+		Token:         os.Getenv("BIKE_SERVICE_TOKEN"),
+
+		Timeout:            getEnvDuration("BIKE_SERVICE_TIMEOUT", 2*time.Second),
+		MaxRetries:         getEnvInt("BIKE_SERVICE_MAX_RETRIES", 2),
+		RetryBaseDelay:     getEnvDuration("BIKE_SERVICE_RETRY_BASE_DELAY", 100*time.Millisecond),
+		BreakerThreshold:   getEnvInt("BIKE_SERVICE_BREAKER_THRESHOLD", 5),
+		BreakerOpenTimeout: getEnvDuration("BIKE_SERVICE_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		CacheTTL:           getEnvDuration("BIKE_SERVICE_CACHE_TTL", 30*time.Second),
+	}
+
+	health := &Health{
+		CheckTimeout: getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+	}
+
+	cache := &Cache{
+		L1Size:              getEnvInt("CACHE_L1_SIZE", 10000),
+		L1TTL:               getEnvDuration("CACHE_L1_TTL", 30*time.Second),
+		InvalidationChannel: getEnv("CACHE_INVALIDATION_CHANNEL", "cache:invalidate"),
+		NegativeTTL:         getEnvDuration("CACHE_NEGATIVE_TTL", time.Minute),
+		EarlyRefreshBeta:    getEnvFloat("CACHE_EARLY_REFRESH_BETA", 0),
+	}
+
+	tracing := &Tracing{
+		Enabled:     getEnvBool("TRACING_ENABLED", false),
+		Endpoint:    getEnv("TRACING_OTLP_ENDPOINT", "localhost:4317"),
+		Insecure:    getEnvBool("TRACING_INSECURE", true),
+		SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+	}
+
+	logging := &Logging{
+		Redact:           getEnvBool("LOG_REDACT", false),
+		RedactHashKey:    os.Getenv("LOG_REDACT_HASH_KEY"),
+		RedactMaskFields: getEnvList("LOG_REDACT_MASK_FIELDS"),
+		RedactHashFields: getEnvList("LOG_REDACT_HASH_FIELDS"),
+		RedactDropFields: getEnvList("LOG_REDACT_DROP_FIELDS"),
+	}
+
+	introspection := &Introspection{
+		Clients: getEnvMap("INTROSPECTION_CLIENTS"),
+	}
+
+	outbox := &Outbox{
+		PollInterval:    getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		BatchSize:       getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		Retention:       getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		CleanupInterval: getEnvDuration("OUTBOX_CLEANUP_INTERVAL", time.Hour),
+		MaxAttempts:     getEnvInt("OUTBOX_MAX_ATTEMPTS", 20),
+		BaseBackoff:     getEnvDuration("OUTBOX_BASE_BACKOFF", time.Second),
+		MaxBackoff:      getEnvDuration("OUTBOX_MAX_BACKOFF", 10*time.Minute),
+	}
+
+	events := &Events{
+		Transport:      getEnv("EVENTS_TRANSPORT", "log"),
+		Source:         getEnv("EVENTS_SOURCE", "/"+app.Name),
+		PublishTimeout: getEnvDuration("EVENTS_PUBLISH_TIMEOUT", 5*time.Second),
+
+		NATSURL:           getEnv("NATS_URL", "nats://localhost:4222"),
+		NATSStream:        getEnv("NATS_STREAM", "USERS"),
+		NATSSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "webike"),
+
+		KafkaBrokers: getEnvList("KAFKA_BROKERS"),
+		KafkaTopic:   getEnv("KAFKA_TOPIC", "webike.users"),
+	}
+
+	webhooks := &Webhooks{
+		MaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		BaseBackoff:  getEnvDuration("WEBHOOK_BASE_BACKOFF", 30*time.Second),
+		MaxBackoff:   getEnvDuration("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+		Timeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		PollInterval: getEnvDuration("WEBHOOK_POLL_INTERVAL", 2*time.Second),
+		BatchSize:    getEnvInt("WEBHOOK_BATCH_SIZE", 50),
+		Concurrency:  getEnvInt("WEBHOOK_CONCURRENCY", 10),
+		Lease:        getEnvDuration("WEBHOOK_LEASE", time.Minute),
+	}
+
+	deletion := &Deletion{
+		MaxAttempts:    getEnvInt("DELETION_MAX_ATTEMPTS", 8),
+		BaseBackoff:    getEnvDuration("DELETION_BASE_BACKOFF", 30*time.Second),
+		MaxBackoff:     getEnvDuration("DELETION_MAX_BACKOFF", time.Hour),
+		PollInterval:   getEnvDuration("DELETION_POLL_INTERVAL", 5*time.Second),
+		BatchSize:      getEnvInt("DELETION_BATCH_SIZE", 20),
+		Lease:          getEnvDuration("DELETION_LEASE", 2*time.Minute),
+		AdvanceTimeout: getEnvDuration("DELETION_ADVANCE_TIMEOUT", 3*time.Second),
 	}
 
 	return &Container{
-		App:         app,
-		Token:       token,
-		DB:          db,
-		HTTP:        http,
-		Redis:       redis,
-		BikeService: bikeService,
+		App:           app,
+		Token:         token,
+		DB:            db,
+		HTTP:          http,
+		GRPC:          grpc,
+		Redis:         redis,
+		BikeService:   bikeService,
+		Health:        health,
+		Cache:         cache,
+		Tracing:       tracing,
+		Logging:       logging,
+		Introspection: introspection,
+		Outbox:        outbox,
+		Events:        events,
+		Webhooks:      webhooks,
+		Deletion:      deletion,
 	}, nil
 }
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return duration
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// Comma separated list, empty items are skipped
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvMap reads comma-separated key:value pairs
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range getEnvList(key) {
+		k, v, ok := strings.Cut(pair, ":")
+		if ok && k != "" && v != "" {
+			result[k] = v
+		}
+	}
+	return result
+}
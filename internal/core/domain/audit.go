@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction string
+
+const (
+	AuditLoginSucceeded AuditAction = "login.succeeded"
+	AuditLoginFailed    AuditAction = "login.failed"
+	AuditUserRegistered AuditAction = "user.registered"
+	AuditUserUpdated    AuditAction = "user.updated"
+	AuditUserDeleted    AuditAction = "user.deleted"
+	AuditRoleChanged    AuditAction = "user.role_changed"
+	AuditTokenRevoked   AuditAction = "token.revoked"
+	AuditUsersImported  AuditAction = "user.imported"
+
+	AuditUserDeletionRequested   AuditAction = "user.deletion_requested"
+	AuditUserDeletionCompensated AuditAction = "user.deletion_compensated"
+
+	AuditServiceClientCreated AuditAction = "service_client.created"
+	AuditServiceClientRotated AuditAction = "service_client.rotated"
+	AuditServiceClientRevoked AuditAction = "service_client.revoked"
+
+	AuditWebhookCreated     AuditAction = "webhook.created"
+	AuditWebhookDeleted     AuditAction = "webhook.deleted"
+	AuditWebhookRedelivered AuditAction = "webhook.redelivered"
+)
+
+// AuditEvent is an append-only record of a security-relevant action.
+// ActorID is empty for anonymous callers, e.g. failed logins
+type AuditEvent struct {
+	ID         uuid.UUID
+	OccurredAt time.Time
+	Action     AuditAction
+	ActorID    *uuid.UUID
+	TargetID   *uuid.UUID
+	Changes    map[string]AuditChange
+	Details    map[string]string
+	IP         string
+	UserAgent  string
+	RequestID  string
+}
+
+// AuditChange holds the old and new value of one field.
+// Secrets are never stored, only the fact that they changed
+type AuditChange struct {
+	Before   interface{} `json:"before,omitempty"`
+	After    interface{} `json:"after,omitempty"`
+	Redacted bool        `json:"redacted,omitempty"`
+}
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 200
+)
+
+type AuditFilter struct {
+	ActorID  *uuid.UUID
+	TargetID *uuid.UUID
+	Action   AuditAction
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// Normalized clamps the page to the allowed size
+func (f AuditFilter) Normalized() AuditFilter {
+	if f.Limit <= 0 {
+		f.Limit = DefaultAuditPageSize
+	}
+	if f.Limit > MaxAuditPageSize {
+		f.Limit = MaxAuditPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
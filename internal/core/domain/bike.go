@@ -0,0 +1,25 @@
+package domain
+
+import "errors"
+
+var ErrBikeServiceUnavailable = errors.New("bike service unavailable")
+
+// Bike as returned by the bike service
+type Bike struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Brand  string `json:"brand"`
+	Model  string `json:"model"`
+	Type   string `json:"type"`
+	Year   int    `json:"year"`
+}
+
+// UserWithBikes is a user with their bikes. When the bikes could not be
+// loaded the user is still returned, with Partial set and the reason why
+type UserWithBikes struct {
+	User          *User
+	Bikes         []Bike
+	Partial       bool
+	PartialReason string
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserStatus string
+
+const (
+	UserActive UserStatus = "active"
+	// Cannot log in or change anything while the deletion runs
+	UserPendingDeletion UserStatus = "pending_deletion"
+)
+
+type DeletionStatus string
+
+const (
+	// The user is locked, the bikes are not removed yet
+	DeletionPending DeletionStatus = "pending"
+	// The bike service is done, only the user row is left.
+	// From here on the deletion is only retried, never compensated
+	DeletionBikesRemoved DeletionStatus = "bikes_removed"
+	DeletionCompleted    DeletionStatus = "completed"
+	// Gave up before touching the bikes, the user is active again
+	DeletionCompensated DeletionStatus = "compensated"
+)
+
+// UserDeletion is the persisted state of the account deletion saga
+type UserDeletion struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Status        DeletionStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	RequestedBy   *uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	CompletedAt   *time.Time
+}
+
+func (d *UserDeletion) Finished() bool {
+	return d.Status == DeletionCompleted || d.Status == DeletionCompensated
+}
+
+type DeletionFilter struct {
+	UserID *uuid.UUID
+	Status DeletionStatus
+	Limit  int
+	Offset int
+}
+
+const (
+	DefaultDeletionPageSize = 50
+	MaxDeletionPageSize     = 200
+)
+
+func (f DeletionFilter) Normalized() DeletionFilter {
+	if f.Limit <= 0 {
+		f.Limit = DefaultDeletionPageSize
+	}
+	if f.Limit > MaxDeletionPageSize {
+		f.Limit = MaxDeletionPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "errors"
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrEmailAlreadyExists = errors.New("email already exists")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidID          = errors.New("invalid ID format")
+	ErrValidation         = errors.New("validation failed")
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrAuthUnavailable    = errors.New("authentication unavailable")
+
+	ErrServiceClientNotFound = errors.New("service client not found")
+	ErrInvalidClient         = errors.New("invalid client credentials")
+	ErrInvalidScope          = errors.New("invalid scope")
+
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	ErrDeletionNotFound   = errors.New("user deletion not found")
+	ErrDeletionInProgress = errors.New("user deletion in progress")
+	ErrDeletionConflict   = errors.New("user deletion changed concurrently")
+)
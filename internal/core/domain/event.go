@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventUserRegistered  EventType = "user.registered"
+	EventUserUpdated     EventType = "user.updated"
+	EventUserDeleted     EventType = "user.deleted"
+	EventUserRoleChanged EventType = "user.role_changed"
+)
+
+// Event is a fact about a user other services may react to.
+// Events of one aggregate are published in the order they happened
+type Event struct {
+	ID          uuid.UUID
+	Type        EventType
+	AggregateID uuid.UUID
+	OccurredAt  time.Time
+	Payload     json.RawMessage
+}
+
+// UserEventPayload is the payload of all user events, never carries the password
+type UserEventPayload struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	Email       string    `json:"email"`
+	DateOfBirth string    `json:"date_of_birth,omitempty"`
+	Role        UserRole  `json:"role,omitempty"`
+	// For updates: which fields changed, and the old email when it did
+	ChangedFields []string `json:"changed_fields,omitempty"`
+	PreviousEmail string   `json:"previous_email,omitempty"`
+	PreviousRole  UserRole `json:"previous_role,omitempty"`
+}
+
+var KnownEventTypes = []EventType{EventUserRegistered, EventUserUpdated, EventUserDeleted, EventUserRoleChanged}
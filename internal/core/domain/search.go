@@ -0,0 +1,33 @@
+package domain
+
+type UserSearchFilter struct {
+	Query string
+	// Spellings of Query in other alphabets, searched as well
+	Variants []string
+	Limit    int
+	Offset   int
+}
+
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+)
+
+func (f UserSearchFilter) Normalized() UserSearchFilter {
+	if f.Limit <= 0 {
+		f.Limit = DefaultSearchPageSize
+	}
+	if f.Limit > MaxSearchPageSize {
+		f.Limit = MaxSearchPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
+
+// UserSearchHit is a search result, higher Rank is a better match
+type UserSearchHit struct {
+	User User
+	Rank float64
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes a service client can be granted
+const (
+	ScopeUsersRead        = "users:read"
+	ScopeUsersWrite       = "users:write"
+	ScopeTokensIntrospect = "tokens:introspect"
+)
+
+var KnownScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeTokensIntrospect}
+
+// ServiceClient is a machine caller such as the bike service.
+// Only a hash of its secret is stored
+type ServiceClient struct {
+	ID         uuid.UUID
+	Name       string
+	Scopes     []string
+	SecretHash string
+	CreatedAt  time.Time
+	RotatedAt  *time.Time
+	RevokedAt  *time.Time
+}
+
+func (c *ServiceClient) Active() bool {
+	return c.RevokedAt == nil
+}
@@ -1,11 +1,66 @@
 package domain
 
 import (
+	"slices"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+type PrincipalType string
+
+const (
+	PrincipalUser    PrincipalType = "user"
+	PrincipalService PrincipalType = "service"
+)
+
+// TokenPayload describes either a user, with UserID and Role set,
+// or a service client, with ClientID and Scopes set
 type TokenPayload struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
-	Role   UserRole
+	ID        uuid.UUID
+	Principal PrincipalType
+	UserID    uuid.UUID
+	Role      UserRole
+	ClientID  uuid.UUID
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (p *TokenPayload) IsService() bool {
+	return p.Principal == PrincipalService
+}
+
+// Subject is the ID of whoever the token was issued to
+func (p *TokenPayload) Subject() uuid.UUID {
+	if p.IsService() {
+		return p.ClientID
+	}
+	return p.UserID
+}
+
+func (p *TokenPayload) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// CanAccessUser allows admins, the user themselves,
+// and service clients granted the scope
+func (p *TokenPayload) CanAccessUser(userID string, scope string) bool {
+	if p.IsService() {
+		return p.HasScope(scope)
+	}
+	return p.Role == Admin || p.UserID.String() == userID
+}
+
+// TokenIntrospection is the RFC 7662 view of a token.
+// Only Active is meaningful when the token is not active
+type TokenIntrospection struct {
+	Active    bool
+	TokenID   uuid.UUID
+	Principal PrincipalType
+	Subject   uuid.UUID
+	Role      UserRole
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription sends matching events to a partner URL.
+// The secret signs the deliveries, so unlike client secrets it is stored as is
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	URL       string
+	Events    []EventType
+	Secret    string
+	CreatedAt time.Time
+}
+
+// WebhookEventPayload is all a partner gets about a user, the rest is
+// fetched through the API so no PII leaves with webhooks
+type WebhookEventPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// Gave up after the maximum number of attempts, only redelivery sends it again
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	Event          Event
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type DeliveryFilter struct {
+	SubscriptionID uuid.UUID
+	Status         DeliveryStatus
+	Limit          int
+	Offset         int
+}
+
+const (
+	DefaultDeliveryPageSize = 50
+	MaxDeliveryPageSize     = 200
+)
+
+func (f DeliveryFilter) Normalized() DeliveryFilter {
+	if f.Limit <= 0 {
+		f.Limit = DefaultDeliveryPageSize
+	}
+	if f.Limit > MaxDeliveryPageSize {
+		f.Limit = MaxDeliveryPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+)
+
+type AuditRepository interface {
+	CreateEvent(ctx context.Context, event *domain.AuditEvent) error
+	// ListEvents returns one page of events, newest first, and the total count
+	ListEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, int, error)
+}
+
+// AuditPort records security-relevant actions.
+// Actor, IP, user agent and request ID are taken from ctx when not set
+type AuditPort interface {
+	Record(ctx context.Context, event *domain.AuditEvent)
+	ListEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, int, error)
+}
@@ -2,15 +2,32 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
 )
 
 type TokenService interface {
 	CreateToken(user *domain.User) (string, error)
+	CreateServiceToken(client *domain.ServiceClient, scopes []string, ttl time.Duration) (string, error)
 	VerifyToken(token string) (domain.TokenPayload, error)
 }
 
 type AuthService interface {
 	Login(ctx context.Context, email, password string) (string, *domain.User, error)
+	// Authenticate verifies the token and rejects revoked ones.
+	// domain.ErrAuthUnavailable means revocation could not be checked
+	Authenticate(ctx context.Context, token string) (domain.TokenPayload, error)
+	// Introspect also checks the account behind the token. domain.ErrAuthUnavailable
+	// means the answer is unknown, not that the token is inactive
+	Introspect(ctx context.Context, token string) (*domain.TokenIntrospection, error)
+	Revoke(ctx context.Context, payload domain.TokenPayload) error
+}
+
+// TokenRevocationStore remembers revoked tokens until they expire
+type TokenRevocationStore interface {
+	Revoke(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID uuid.UUID) (bool, error)
 }
@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+type BikeServicePort interface {
+	// GetUserBikes returns the bikes of the owner of token.
+	// domain.ErrBikeServiceUnavailable means the call may succeed later
+	GetUserBikes(ctx context.Context, token string) ([]domain.Bike, error)
+	// DeleteUserBikes removes or hands over the bikes of a deleted user,
+	// a user without bikes is not an error
+	DeleteUserBikes(ctx context.Context, userID uuid.UUID) error
+}
@@ -1,9 +1,37 @@
 package ports
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Returned by cache adapters when the key does not exist
+var ErrCacheMiss = errors.New("cache miss")
+
+type CacheItem struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration
+	Tags  []string
+}
 
 type CachePort interface {
-	Get(key string) ([]byte, error)
-	Set(key string, value []byte, ttl time.Duration) error
-	Delete(key string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Tags group keys so they can be invalidated together
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	Delete(ctx context.Context, key string) error
+
+	// Batch operations, missing keys are absent from the MGet result
+	MGet(ctx context.Context, keys ...string) (map[string][]byte, error)
+	MSet(ctx context.Context, items ...CacheItem) error
+	DeleteMany(ctx context.Context, keys ...string) error
+
+	// Deletes every key set with any of the tags
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// Implemented by caches that keep a tag index
+type CacheTagResolver interface {
+	TagKeys(ctx context.Context, tags ...string) ([]string, error)
 }
@@ -0,0 +1,41 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+type UserDeletionRepository interface {
+	// StartDeletion locks the user and records a pending deletion in one transaction,
+	// hidden from ClaimDueDeletions for lease so the caller can advance it first.
+	// domain.ErrDeletionInProgress means one is already running
+	StartDeletion(ctx context.Context, userID uuid.UUID, requestedBy *uuid.UUID, lease time.Duration) (*domain.UserDeletion, error)
+	GetDeletion(ctx context.Context, id uuid.UUID) (*domain.UserDeletion, error)
+	ListDeletions(ctx context.Context, filter domain.DeletionFilter) ([]domain.UserDeletion, int, error)
+	// ClaimDueDeletions hides the returned deletions from other workers for lease
+	ClaimDueDeletions(ctx context.Context, limit int, lease time.Duration) ([]domain.UserDeletion, error)
+	// SaveDeletion, FinalizeDeletion and CompensateDeletion only write a deletion
+	// still stored with status from, domain.ErrDeletionConflict means another worker moved it
+	SaveDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error
+	// FinalizeDeletion deletes the user and saves deletion in one transaction
+	FinalizeDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error
+	// CompensateDeletion makes the user active again and saves deletion in one transaction
+	CompensateDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error
+}
+
+// UserDeletionService runs the account deletion saga:
+// lock the user, remove the bikes, delete the user
+type UserDeletionService interface {
+	// StartDeletion locks the user and records the deletion,
+	// joining the transaction in ctx if there is one
+	StartDeletion(ctx context.Context, user *domain.User) (*domain.UserDeletion, error)
+	// Advance runs as much of the saga as it can right away,
+	// the rest is retried in the background
+	Advance(ctx context.Context, deletion *domain.UserDeletion)
+	GetDeletion(ctx context.Context, id string) (*domain.UserDeletion, error)
+	ListDeletions(ctx context.Context, filter domain.DeletionFilter) ([]domain.UserDeletion, int, error)
+}
@@ -0,0 +1,13 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+)
+
+// EventPublisher delivers events to other services.
+// Delivery is at least once, consumers must tolerate duplicates by event ID
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+}
@@ -0,0 +1,16 @@
+package ports
+
+import "context"
+
+type HealthChecker interface {
+	// Name of the dependency as shown in the readiness output
+	Name() string
+	// Critical dependencies flip readiness to not-ready when they fail
+	Critical() bool
+	Check(ctx context.Context) error
+}
+
+// Optionally implemented by checkers that report extra state, e.g. a circuit breaker
+type HealthDetailer interface {
+	HealthDetails() map[string]string
+}
@@ -9,6 +9,12 @@ type LoggerPort interface {
 	Debug(msg string, fields map[string]interface{})
 	Warn(msg string, fields map[string]interface{})
 
+	// Methods for request scope, add request ID, route, user ID and trace ID from ctx
+	InfoContext(ctx context.Context, msg string, fields map[string]interface{})
+	ErrorContext(ctx context.Context, msg string, fields map[string]interface{})
+	DebugContext(ctx context.Context, msg string, fields map[string]interface{})
+	WarnContext(ctx context.Context, msg string, fields map[string]interface{})
+
 	// Methods for gRPC
 	InfoGRPC(ctx context.Context, msg string, fields any)
 	ErrorGRPC(ctx context.Context, msg string, fields any)
@@ -2,12 +2,53 @@ package ports
 
 import (
 	"time"
+)
+
+// Metric names known to the metrics adapter
+const (
+	MetricHTTPRequests        = "http_requests_total"
+	MetricHTTPRequestDuration = "api_request_duration_seconds"
+	MetricHTTPRequestSize     = "http_request_size_bytes"
+	MetricHTTPResponseSize    = "http_response_size_bytes"
+	MetricHTTPInFlight        = "http_requests_in_flight"
+
+	MetricGRPCRequests        = "grpc_requests_total"
+	MetricGRPCRequestDuration = "grpc_request_duration_seconds"
+
+	MetricRegistrations = "user_registrations_total"
+	MetricLogins        = "user_logins_total"
+	MetricDeletions     = "user_deletions_total"
+
+	MetricCacheRequests       = "cache_requests_total"
+	MetricCircuitBreakerState = "circuit_breaker_state"
+
+	MetricOutboxPublished = "outbox_events_published_total"
+	MetricOutboxPending   = "outbox_events_pending"
+
+	MetricEventsPublished      = "events_published_total"
+	MetricEventPublishDuration = "event_publish_duration_seconds"
+
+	MetricWebhookDeliveries = "webhook_deliveries_total"
+
+	MetricDBReplicaHealthy = "db_replica_healthy"
+)
 
-	"github.com/gin-gonic/gin"
+// Values of the "outcome" label of business counters
+const (
+	OutcomeSuccess            = "success"
+	OutcomeInvalidCredentials = "invalid_credentials"
+	OutcomeInvalid            = "invalid"
+	OutcomeConflict           = "conflict"
+	OutcomeNotFound           = "not_found"
+	OutcomeError              = "error"
+	OutcomeRetry              = "retry"
+	OutcomeDead               = "dead"
 )
 
 type MetricsPort interface {
 	IncrementCounter(name string, labels map[string]string)
 	RecordDuration(name string, duration time.Duration, labels map[string]string)
-	RecordMetrics(c *gin.Context, start time.Time)
+	ObserveValue(name string, value float64, labels map[string]string)
+	SetGauge(name string, value float64, labels map[string]string)
+	AddGauge(name string, delta float64, labels map[string]string)
 }
@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+type ServiceClientRepository interface {
+	CreateClient(ctx context.Context, client *domain.ServiceClient) (*domain.ServiceClient, error)
+	GetClientByID(ctx context.Context, id uuid.UUID) (*domain.ServiceClient, error)
+	ListClients(ctx context.Context) ([]domain.ServiceClient, error)
+	UpdateClientSecret(ctx context.Context, id uuid.UUID, secretHash string) (*domain.ServiceClient, error)
+	RevokeClient(ctx context.Context, id uuid.UUID) error
+}
+
+// ServiceClientService manages machine clients and issues them tokens.
+// Secrets are returned only when created or rotated
+type ServiceClientService interface {
+	CreateClient(ctx context.Context, name string, scopes []string) (*domain.ServiceClient, string, error)
+	ListClients(ctx context.Context) ([]domain.ServiceClient, error)
+	RotateSecret(ctx context.Context, id string) (*domain.ServiceClient, string, error)
+	RevokeClient(ctx context.Context, id string) error
+	// AuthenticateClient checks the client credentials and that the client is active
+	AuthenticateClient(ctx context.Context, clientID, secret string) (*domain.ServiceClient, error)
+	// IssueToken grants the requested scopes, or all of the client's scopes when none are requested
+	IssueToken(ctx context.Context, clientID, secret string, scopes []string) (string, []string, time.Duration, error)
+}
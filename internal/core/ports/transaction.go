@@ -0,0 +1,27 @@
+package ports
+
+import (
+	"context"
+)
+
+// IsolationLevel of a transaction run by TransactionManager
+type IsolationLevel int
+
+const (
+	// Read committed in Postgres
+	IsolationDefault IsolationLevel = iota
+	// Fails with a serialization error, and is retried, when a row read was changed concurrently
+	IsolationRepeatableRead
+	IsolationSerializable
+)
+
+type TransactionManager interface {
+	// WithinTx runs fn in one transaction. Repositories called with the ctx
+	// passed to fn take part in it, nested calls run in savepoints.
+	// fn may run more than once after a serialization failure or deadlock,
+	// so effects outside the database belong after WithinTx returns
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// WithinTxIsolation is WithinTx at level. A nested call cannot raise
+	// the level of the transaction it joins and fails instead
+	WithinTxIsolation(ctx context.Context, level IsolationLevel, fn func(ctx context.Context) error) error
+}
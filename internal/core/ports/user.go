@@ -11,13 +11,28 @@ type UserRepository interface {
 	CreateUser(ctx context.Context, user *domain.User) (*domain.User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	// GetUsersByIDs skips unknown IDs
+	GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.User, error)
+	// ImportUsers inserts all users or none. Passwords must be hashed already,
+	// missing IDs, roles and statuses are filled in
+	ImportUsers(ctx context.Context, users []domain.User) error
 	UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error)
 	DeleteUser(ctx context.Context, id uuid.UUID) error
+	UpdateUserRole(ctx context.Context, id uuid.UUID, role domain.UserRole) (*domain.User, error)
+	SearchUsers(ctx context.Context, filter domain.UserSearchFilter) ([]domain.UserSearchHit, int, error)
 }
 
 type UserService interface {
 	Register(ctx context.Context, user *domain.User) (*domain.User, error)
 	GetUser(ctx context.Context, id string) (*domain.User, error)
+	// GetUsers returns the users found and the IDs that were not
+	GetUsers(ctx context.Context, ids []string) ([]domain.User, []string, error)
 	UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error)
-	DeleteUser(ctx context.Context, id string) error
+	// DeleteUser starts the deletion saga, the user may still exist when it returns
+	DeleteUser(ctx context.Context, id string) (*domain.UserDeletion, error)
+	ChangeRole(ctx context.Context, id string, role domain.UserRole) (*domain.User, error)
+	// ImportUsers creates all users or none, passwords may already be bcrypt hashes
+	ImportUsers(ctx context.Context, users []domain.User) error
+	// SearchUsers finds users by partial or misspelled name or email, in either alphabet
+	SearchUsers(ctx context.Context, filter domain.UserSearchFilter) ([]domain.UserSearchHit, int, error)
 }
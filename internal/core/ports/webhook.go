@@ -0,0 +1,44 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id uuid.UUID) (*domain.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	// ListSubscriptionsForEvent returns the subscriptions whose filter matches eventType
+	ListSubscriptionsForEvent(ctx context.Context, eventType domain.EventType) ([]domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// EnqueueDeliveries skips deliveries already queued for the same subscription and event
+	EnqueueDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error
+	// ClaimDueDeliveries hides the returned deliveries from other workers for lease
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	// SaveAttempt stores the status, attempts, next attempt and last result of delivery
+	SaveAttempt(ctx context.Context, delivery *domain.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id uuid.UUID) (*domain.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter domain.DeliveryFilter) ([]domain.WebhookDelivery, int, error)
+}
+
+// WebhookSender signs and sends one delivery, returning the HTTP status of the response
+type WebhookSender interface {
+	Send(ctx context.Context, subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) (int, error)
+}
+
+// WebhookService manages partner subscriptions and delivers events to them.
+// Secrets are returned only when a subscription is created
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, url string, events []domain.EventType) (*domain.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, subscriptionID string, filter domain.DeliveryFilter) ([]domain.WebhookDelivery, int, error)
+	// Redeliver queues the delivery again with a fresh attempt budget
+	Redeliver(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error)
+}
@@ -0,0 +1,73 @@
+// Package requestctx carries per-request metadata through context.Context,
+// so the service layer and loggers can see who is calling without Gin
+package requestctx
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	routeKey
+	payloadKey
+	clientKey
+	primaryKey
+)
+
+// Client describes where the request came from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
+
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey).(string)
+	return route
+}
+
+// WithPayload stores the verified token of the caller
+func WithPayload(ctx context.Context, payload *domain.TokenPayload) context.Context {
+	return context.WithValue(ctx, payloadKey, payload)
+}
+
+func Payload(ctx context.Context) (*domain.TokenPayload, bool) {
+	payload, ok := ctx.Value(payloadKey).(*domain.TokenPayload)
+	return payload, ok && payload != nil
+}
+
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+func ClientInfo(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey).(Client)
+	return client
+}
+
+// WithPrimary makes repositories read from the primary database,
+// for reads that must see a write made just before
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey, true)
+}
+
+func PrimaryRequired(ctx context.Context) bool {
+	required, _ := ctx.Value(primaryKey).(bool)
+	return required
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+)
+
+type AuditService struct {
+	repo   ports.AuditRepository
+	logger ports.LoggerPort
+}
+
+func NewAuditService(repo ports.AuditRepository, logger ports.LoggerPort) *AuditService {
+	return &AuditService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Record never fails the audited operation, a lost event is logged instead
+func (s *AuditService) Record(ctx context.Context, event *domain.AuditEvent) {
+	if event.ActorID == nil {
+		if payload, ok := requestctx.Payload(ctx); ok {
+			actorID := payload.Subject()
+			event.ActorID = &actorID
+			if payload.IsService() {
+				if event.Details == nil {
+					event.Details = make(map[string]string)
+				}
+				event.Details["actor_type"] = string(domain.PrincipalService)
+			}
+		}
+	}
+
+	client := requestctx.ClientInfo(ctx)
+	if event.IP == "" {
+		event.IP = client.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+	if event.RequestID == "" {
+		event.RequestID = requestctx.RequestID(ctx)
+	}
+
+	// The request may be cancelled right after the action succeeded
+	if err := s.repo.CreateEvent(context.WithoutCancel(ctx), event); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to record audit event", map[string]interface{}{
+			"error":  err.Error(),
+			"action": string(event.Action),
+		})
+	}
+}
+
+func (s *AuditService) ListEvents(ctx context.Context, filter domain.AuditFilter) (_ []domain.AuditEvent, _ int, err error) {
+	ctx, span := startSpan(ctx, "AuditService.ListEvents")
+	defer func() { endSpan(span, err) }()
+
+	events, total, err := s.repo.ListEvents(ctx, filter.Normalized())
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to list audit events", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// userChanges lists the fields that differ between two versions of a user,
+// either side may be nil for created and deleted users
+func userChanges(before, after *domain.User) map[string]domain.AuditChange {
+	var old, cur domain.User
+	if before != nil {
+		old = *before
+	}
+	if after != nil {
+		cur = *after
+	}
+
+	changes := make(map[string]domain.AuditChange)
+	diff := func(field string, from, to string) {
+		if from == to {
+			return
+		}
+		change := domain.AuditChange{}
+		if from != "" {
+			change.Before = from
+		}
+		if to != "" {
+			change.After = to
+		}
+		changes[field] = change
+	}
+
+	diff("name", old.Name, cur.Name)
+	diff("email", old.Email, cur.Email)
+	diff("date_of_birth", old.DateOfBirth, cur.DateOfBirth)
+	diff("role", string(old.Role), string(cur.Role))
+
+	// Hashes only, and even those stay out of the log
+	if old.Password != cur.Password {
+		changes["password"] = domain.AuditChange{Redacted: true}
+	}
+
+	return changes
+}
+
+var _ ports.AuditPort = (*AuditService)(nil)
@@ -2,99 +2,314 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
+
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+type AuthSettings struct {
+	// Accept tokens whose revocation cannot be checked, e.g. while Redis is down,
+	// instead of failing with domain.ErrAuthUnavailable
+	RevocationFailOpen bool
+	// How long the active state of a service client is reused,
+	// revoking a client takes effect within this time
+	ClientCacheTTL time.Duration
+}
+
 type AuthService struct {
 	userRepo     ports.UserRepository
+	clientRepo   ports.ServiceClientRepository
 	tokenService ports.TokenService
+	revocations  ports.TokenRevocationStore
 	logger       ports.LoggerPort
-	cache        ports.CachePort
+	metrics      ports.MetricsPort
+	audit        ports.AuditPort
+	cache        *userLoader
+	settings     AuthSettings
+
+	clientsMu sync.Mutex
+	clients   map[uuid.UUID]clientStatus
+}
+
+type clientStatus struct {
+	active    bool
+	expiresAt time.Time
 }
 
 func NewAuthService(
 	userRepo ports.UserRepository,
+	clientRepo ports.ServiceClientRepository,
 	tokenService ports.TokenService,
+	revocations ports.TokenRevocationStore,
 	logger ports.LoggerPort,
+	metrics ports.MetricsPort,
+	audit ports.AuditPort,
 	cache ports.CachePort,
+	cacheSettings CacheSettings,
+	settings AuthSettings,
 ) *AuthService {
 	return &AuthService{
 		userRepo:     userRepo,
+		clientRepo:   clientRepo,
 		tokenService: tokenService,
+		revocations:  revocations,
 		logger:       logger,
-		cache:        cache,
+		metrics:      metrics,
+		audit:        audit,
+		cache:        newUserLoader(cache, logger, cacheSettings),
+		settings:     settings,
+		clients:      make(map[uuid.UUID]clientStatus),
 	}
 }
 
-func (s *AuthService) Login(ctx context.Context, email, password string) (string, *domain.User, error) {
-	cacheKey := fmt.Sprintf("user_email:%s", email)
-	cachedData, err := s.cache.Get(cacheKey)
-	var user *domain.User
+func (s *AuthService) Login(ctx context.Context, email, password string) (_ string, _ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "AuthService.Login")
+	defer func() { endSpan(span, err) }()
+	defer func() { recordOutcome(s.metrics, ports.MetricLogins, err) }()
 
-	if err == nil {
-		var cachedUser domain.User
-		if err := json.Unmarshal(cachedData, &cachedUser); err == nil {
-			user = &cachedUser
-			s.logger.Info("User found in email cache", map[string]interface{}{
-				"email": email,
-			})
+	// Unknown emails are cached too, so enumeration does not reach the database.
+	// Misses read the primary, a replica may not have a new password yet
+	cacheKey := fmt.Sprintf("user_email:%s", email)
+	user, err := s.cache.load(ctx, cacheKey, 10*time.Minute, func(ctx context.Context) (*domain.User, error) {
+		user, err := s.userRepo.GetUserByEmail(requestctx.WithPrimary(ctx), email)
+		if err == nil && user == nil {
+			return nil, domain.ErrUserNotFound
 		}
-	}
-
-	if user == nil {
-		user, err = s.userRepo.GetUserByEmail(ctx, email)
-		if err != nil {
-			s.logger.Error("Failed to get user by email", map[string]interface{}{
+		return user, err
+	})
+	if err != nil {
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			s.logger.ErrorContext(ctx, "Failed to get user by email", map[string]interface{}{
 				"email": email,
 				"error": err.Error(),
 			})
-			return "", nil, errors.New("invalid credentials")
-		}
-
-		if user == nil {
-			return "", nil, errors.New("invalid credentials")
-		}
-
-		userData, err := json.Marshal(user)
-		if err != nil {
-			s.logger.Warn("Failed to marshal user for email cache", map[string]interface{}{
-				"error": err.Error(),
-				"email": email,
-			})
-		} else {
-			if err := s.cache.Set(cacheKey, userData, 10*time.Minute); err != nil {
-				s.logger.Warn("Failed to cache user by email", map[string]interface{}{
-					"error": err.Error(),
-					"email": email,
-				})
-			}
+			return "", nil, domain.ErrInvalidCredentials
 		}
+		s.recordLoginFailure(ctx, nil, email, "unknown_email")
+		return "", nil, domain.ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		s.logger.Info("Invalid password attempt", map[string]interface{}{
+		s.logger.InfoContext(ctx, "Invalid password attempt", map[string]interface{}{
 			"email": email,
 		})
-		return "", nil, errors.New("invalid credentials")
+		s.recordLoginFailure(ctx, &user.ID, email, "invalid_password")
+		return "", nil, domain.ErrInvalidCredentials
+	}
+	if user.Status == domain.UserPendingDeletion {
+		s.recordLoginFailure(ctx, &user.ID, email, "pending_deletion")
+		return "", nil, domain.ErrInvalidCredentials
 	}
 
 	token, err := s.tokenService.CreateToken(user)
 	if err != nil {
-		s.logger.Error("Failed to create token", map[string]interface{}{
+		s.logger.ErrorContext(ctx, "Failed to create token", map[string]interface{}{
 			"error":   err.Error(),
 			"user_id": user.ID,
 		})
 		return "", nil, err
 	}
 
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditLoginSucceeded,
+		ActorID:  &user.ID,
+		TargetID: &user.ID,
+	})
+
 	userResponse := *user
 	userResponse.Password = ""
 	return token, &userResponse, nil
 }
+
+func (s *AuthService) recordLoginFailure(ctx context.Context, userID *uuid.UUID, email, reason string) {
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditLoginFailed,
+		TargetID: userID,
+		Details: map[string]string{
+			"email":  email,
+			"reason": reason,
+		},
+	})
+}
+
+// Authenticate fails closed when the revocation store is unavailable,
+// unless AuthSettings.RevocationFailOpen lets unchecked tokens pass
+func (s *AuthService) Authenticate(ctx context.Context, token string) (domain.TokenPayload, error) {
+	payload, err := s.tokenService.VerifyToken(token)
+	if err != nil {
+		return domain.TokenPayload{}, err
+	}
+
+	revoked, err := s.isRevoked(ctx, payload.ID)
+	if err != nil {
+		return domain.TokenPayload{}, err
+	}
+	if revoked {
+		return domain.TokenPayload{}, domain.ErrTokenRevoked
+	}
+
+	// Revoking a client cuts off its tokens at once
+	if payload.IsService() {
+		active, err := s.clientActive(ctx, payload.ClientID)
+		if err != nil {
+			return domain.TokenPayload{}, fmt.Errorf("%w: get token client: %w", domain.ErrAuthUnavailable, err)
+		}
+		if !active {
+			return domain.TokenPayload{}, domain.ErrTokenRevoked
+		}
+	}
+	return payload, nil
+}
+
+func (s *AuthService) Introspect(ctx context.Context, token string) (_ *domain.TokenIntrospection, err error) {
+	ctx, span := startSpan(ctx, "AuthService.Introspect")
+	defer func() { endSpan(span, err) }()
+
+	inactive := &domain.TokenIntrospection{Active: false}
+
+	payload, err := s.tokenService.VerifyToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	revoked, err := s.isRevoked(ctx, payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	if payload.IsService() {
+		active, err := s.clientActive(ctx, payload.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: get token client: %w", domain.ErrAuthUnavailable, err)
+		}
+		if !active {
+			return inactive, nil
+		}
+
+		return &domain.TokenIntrospection{
+			Active:    true,
+			TokenID:   payload.ID,
+			Principal: domain.PrincipalService,
+			Subject:   payload.ClientID,
+			Scopes:    payload.Scopes,
+			IssuedAt:  payload.IssuedAt,
+			ExpiresAt: payload.ExpiresAt,
+		}, nil
+	}
+
+	// The account may have been deleted or demoted since the token was issued
+	cacheKey := fmt.Sprintf("user:%s", payload.UserID.String())
+	user, err := s.cache.load(ctx, cacheKey, 15*time.Minute, func(ctx context.Context) (*domain.User, error) {
+		return s.userRepo.GetUserByID(ctx, payload.UserID)
+	})
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: get token subject: %w", domain.ErrAuthUnavailable, err)
+	}
+	if user.Role != payload.Role || user.Status == domain.UserPendingDeletion {
+		return inactive, nil
+	}
+
+	return &domain.TokenIntrospection{
+		Active:    true,
+		TokenID:   payload.ID,
+		Principal: domain.PrincipalUser,
+		Subject:   payload.UserID,
+		Role:      payload.Role,
+		IssuedAt:  payload.IssuedAt,
+		ExpiresAt: payload.ExpiresAt,
+	}, nil
+}
+
+func (s *AuthService) Revoke(ctx context.Context, payload domain.TokenPayload) (err error) {
+	ctx, span := startSpan(ctx, "AuthService.Revoke")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.revocations.Revoke(ctx, payload.ID, payload.ExpiresAt); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to revoke token", map[string]interface{}{
+			"error":   err.Error(),
+			"subject": payload.Subject(),
+		})
+		return err
+	}
+
+	subject := payload.Subject()
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditTokenRevoked,
+		TargetID: &subject,
+		Details: map[string]string{
+			"token_id": payload.ID.String(),
+		},
+	})
+	return nil
+}
+
+// isRevoked reports a store failure as domain.ErrAuthUnavailable, or as not revoked when failing open
+func (s *AuthService) isRevoked(ctx context.Context, tokenID uuid.UUID) (bool, error) {
+	revoked, err := s.revocations.IsRevoked(ctx, tokenID)
+	if err == nil {
+		return revoked, nil
+	}
+
+	if s.settings.RevocationFailOpen {
+		s.logger.DebugContext(ctx, "Token accepted without revocation check", map[string]interface{}{
+			"token_id": tokenID,
+			"error":    err.Error(),
+		})
+		return false, nil
+	}
+
+	s.logger.ErrorContext(ctx, "Failed to check token revocation", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return false, fmt.Errorf("%w: check token revocation: %w", domain.ErrAuthUnavailable, err)
+}
+
+// clientActive is asked on every service token request,
+// so the answer is kept in memory for AuthSettings.ClientCacheTTL
+func (s *AuthService) clientActive(ctx context.Context, clientID uuid.UUID) (bool, error) {
+	now := time.Now()
+
+	s.clientsMu.Lock()
+	status, ok := s.clients[clientID]
+	s.clientsMu.Unlock()
+	if ok && now.Before(status.expiresAt) {
+		return status.active, nil
+	}
+
+	active := false
+	client, err := s.clientRepo.GetClientByID(ctx, clientID)
+	switch {
+	case err == nil:
+		active = client.Active()
+	case !errors.Is(err, domain.ErrServiceClientNotFound):
+		return false, err
+	}
+
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	// Expired entries go on the next write, there are only a few clients
+	for id, status := range s.clients {
+		if !now.Before(status.expiresAt) {
+			delete(s.clients, id)
+		}
+	}
+	s.clients[clientID] = clientStatus{
+		active:    active,
+		expiresAt: now.Add(s.settings.ClientCacheTTL),
+	}
+	return active, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// bikeLoader reads bike lists through the cache for a short while,
+// so a burst of profile views makes one call to the bike service
+type bikeLoader struct {
+	bikes  ports.BikeServicePort
+	cache  ports.CachePort
+	logger ports.LoggerPort
+	ttl    time.Duration
+}
+
+func newBikeLoader(bikes ports.BikeServicePort, cache ports.CachePort, logger ports.LoggerPort, ttl time.Duration) *bikeLoader {
+	return &bikeLoader{
+		bikes:  bikes,
+		cache:  cache,
+		logger: logger,
+		ttl:    ttl,
+	}
+}
+
+func (l *bikeLoader) load(ctx context.Context, userID string, token string) ([]domain.Bike, error) {
+	key := fmt.Sprintf("bikes:%s", userID)
+
+	if data, err := l.cache.Get(ctx, key); err == nil {
+		var bikes []domain.Bike
+		if err := json.Unmarshal(data, &bikes); err == nil {
+			return bikes, nil
+		}
+	}
+
+	bikes, err := l.bikes.GetUserBikes(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.ttl > 0 {
+		data, err := json.Marshal(bikes)
+		if err == nil {
+			err = l.cache.Set(ctx, key, data, l.ttl, userCacheTag(userID))
+		}
+		if err != nil {
+			l.logger.WarnContext(ctx, "Failed to cache bikes", map[string]interface{}{
+				"error":   err.Error(),
+				"user_id": userID,
+			})
+		}
+	}
+	return bikes, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+)
+
+// Secrets carry 256 random bits, so a plain SHA-256 is enough to store them
+const clientSecretPrefix = "sk_"
+
+type ServiceClientService struct {
+	repo         ports.ServiceClientRepository
+	tokenService ports.TokenService
+	logger       ports.LoggerPort
+	audit        ports.AuditPort
+	tokenTTL     time.Duration
+}
+
+func NewServiceClientService(
+	repo ports.ServiceClientRepository,
+	tokenService ports.TokenService,
+	logger ports.LoggerPort,
+	audit ports.AuditPort,
+	tokenTTL time.Duration,
+) *ServiceClientService {
+	return &ServiceClientService{
+		repo:         repo,
+		tokenService: tokenService,
+		logger:       logger,
+		audit:        audit,
+		tokenTTL:     tokenTTL,
+	}
+}
+
+func (s *ServiceClientService) CreateClient(ctx context.Context, name string, scopes []string) (_ *domain.ServiceClient, _ string, err error) {
+	ctx, span := startSpan(ctx, "ServiceClientService.CreateClient")
+	defer func() { endSpan(span, err) }()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", domain.ErrValidation)
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	secret, hash, err := newClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := s.repo.CreateClient(ctx, &domain.ServiceClient{
+		Name:       name,
+		Scopes:     scopes,
+		SecretHash: hash,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to create service client", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, "", err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditServiceClientCreated,
+		TargetID: &client.ID,
+		Changes: map[string]domain.AuditChange{
+			"name":   {After: client.Name},
+			"scopes": {After: client.Scopes},
+		},
+	})
+	return client, secret, nil
+}
+
+func (s *ServiceClientService) ListClients(ctx context.Context) (_ []domain.ServiceClient, err error) {
+	ctx, span := startSpan(ctx, "ServiceClientService.ListClients")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.ListClients(ctx)
+}
+
+// RotateSecret replaces the secret, tokens issued with the old one stay valid until they expire
+func (s *ServiceClientService) RotateSecret(ctx context.Context, id string) (_ *domain.ServiceClient, _ string, err error) {
+	ctx, span := startSpan(ctx, "ServiceClientService.RotateSecret")
+	defer func() { endSpan(span, err) }()
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
+	}
+
+	secret, hash, err := newClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := s.repo.UpdateClientSecret(ctx, clientID, hash)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditServiceClientRotated,
+		TargetID: &client.ID,
+		Changes: map[string]domain.AuditChange{
+			"secret": {Redacted: true},
+		},
+	})
+	return client, secret, nil
+}
+
+func (s *ServiceClientService) RevokeClient(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "ServiceClientService.RevokeClient")
+	defer func() { endSpan(span, err) }()
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
+	}
+
+	if err := s.repo.RevokeClient(ctx, clientID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditServiceClientRevoked,
+		TargetID: &clientID,
+	})
+	return nil
+}
+
+func (s *ServiceClientService) AuthenticateClient(ctx context.Context, clientID, secret string) (*domain.ServiceClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, domain.ErrInvalidClient
+	}
+
+	client, err := s.repo.GetClientByID(ctx, id)
+	if errors.Is(err, domain.ErrServiceClientNotFound) {
+		return nil, domain.ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashClientSecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 || !client.Active() {
+		return nil, domain.ErrInvalidClient
+	}
+	return client, nil
+}
+
+func (s *ServiceClientService) IssueToken(ctx context.Context, clientID, secret string, scopes []string) (_ string, _ []string, _ time.Duration, err error) {
+	ctx, span := startSpan(ctx, "ServiceClientService.IssueToken")
+	defer func() { endSpan(span, err) }()
+
+	client, err := s.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidClient) {
+			s.logger.WarnContext(ctx, "Invalid client credentials", map[string]interface{}{
+				"client_id": clientID,
+			})
+		}
+		return "", nil, 0, err
+	}
+
+	granted := client.Scopes
+	if len(scopes) > 0 {
+		for _, scope := range scopes {
+			if !slices.Contains(client.Scopes, scope) {
+				return "", nil, 0, fmt.Errorf("%w: %s", domain.ErrInvalidScope, scope)
+			}
+		}
+		granted = scopes
+	}
+
+	token, err := s.tokenService.CreateServiceToken(client, granted, s.tokenTTL)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to create service token", map[string]interface{}{
+			"error":     err.Error(),
+			"client_id": clientID,
+		})
+		return "", nil, 0, err
+	}
+	return token, granted, s.tokenTTL, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", domain.ErrValidation)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(domain.KnownScopes, scope) {
+			return fmt.Errorf("%w: %s", domain.ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+func newClientSecret() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret := clientSecretPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return secret, hashClientSecret(secret), nil
+}
+
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ ports.ServiceClientService = (*ServiceClientService)(nil)
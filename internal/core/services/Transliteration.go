@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Russian letters in the Latin spelling riders tend to type
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// Longest sequences first, so "shch" wins over "sh"
+var latinToCyrillic = []struct {
+	latin    string
+	cyrillic string
+}{
+	{"shch", "щ"},
+	{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yu", "ю"}, {"ya", "я"}, {"yo", "ё"},
+	{"a", "а"}, {"b", "б"}, {"c", "к"}, {"d", "д"}, {"e", "е"}, {"f", "ф"},
+	{"g", "г"}, {"h", "х"}, {"i", "и"}, {"j", "дж"}, {"k", "к"}, {"l", "л"},
+	{"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"}, {"q", "к"}, {"r", "р"},
+	{"s", "с"}, {"t", "т"}, {"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "кс"},
+	{"y", "й"}, {"z", "з"},
+}
+
+// transliterations returns q spelled in the other alphabet,
+// or nothing when q has no letters to convert
+func transliterations(q string) []string {
+	q = strings.ToLower(q)
+
+	var variant string
+	if strings.IndexFunc(q, isCyrillic) >= 0 {
+		variant = toLatin(q)
+	} else {
+		variant = toCyrillic(q)
+	}
+
+	if variant == q {
+		return nil
+	}
+	return []string{variant}
+}
+
+func toLatin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func toCyrillic(s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		matched := false
+		for _, pair := range latinToCyrillic {
+			if strings.HasPrefix(s, pair.latin) {
+				b.WriteString(pair.cyrillic)
+				s = s[len(pair.latin):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			// Digits, punctuation and email parts stay as typed
+			b.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+	return b.String()
+}
+
+func isCyrillic(r rune) bool {
+	return unicode.Is(unicode.Cyrillic, r)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTransliterations(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want []string
+	}{
+		{name: "empty", q: "", want: nil},
+		{name: "no letters", q: "+7 900 123-45-67", want: nil},
+		{name: "cyrillic", q: "Иван", want: []string{"ivan"}},
+		{name: "latin", q: "Ivan", want: []string{"иван"}},
+		{name: "longest sequence first", q: "Shchukin", want: []string{"щукин"}},
+		{name: "digraphs", q: "Zhanna Khabarova", want: []string{"жанна хабарова"}},
+		{name: "soft and hard signs are dropped", q: "Подъезд Игорь", want: []string{"podezd igor"}},
+		{name: "yo spelled as e", q: "Алёна", want: []string{"alena"}},
+		{name: "letters with two sounds", q: "Max Jones", want: []string{"макс джонес"}},
+		{name: "email parts stay", q: "ivan@mail.ru", want: []string{"иван@маил.ру"}},
+		{name: "mixed alphabets go to latin", q: "Иван ivan", want: []string{"ivan ivan"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transliterations(tt.q); !slices.Equal(got, tt.want) {
+				t.Errorf("transliterations(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+// Names spelled without lossy letters survive the way to Latin and back
+func TestTransliterations_RoundTrip(t *testing.T) {
+	names := []string{
+		"иван",
+		"юлия",
+		"щукин",
+		"жанна",
+		"чехов",
+		"цветаева",
+		"шишкин",
+		"хабаров",
+		"яковлев",
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			latin := transliterations(name)
+			if len(latin) != 1 {
+				t.Fatalf("transliterations(%q) = %q, want one variant", name, latin)
+			}
+			back := transliterations(latin[0])
+			if len(back) != 1 || back[0] != name {
+				t.Errorf("%q -> %q -> %q, want the name back", name, latin[0], back)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
+
+	"github.com/google/uuid"
+)
+
+type DeletionSettings struct {
+	// Failed bike service calls before the deletion is compensated
+	MaxAttempts int
+	// Delay before the second attempt, doubled for every next one
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	// Claimed deletions are hidden from other replicas this long
+	Lease time.Duration
+	// How long Advance runs steps for the request that started the deletion
+	AdvanceTimeout time.Duration
+}
+
+// UserDeletionService deletes accounts in steps that survive restarts:
+// pending (user locked) -> bikes_removed -> completed.
+// A pending deletion the bike service keeps refusing is compensated by
+// unlocking the user; once the bikes are gone it is only retried
+type UserDeletionService struct {
+	repo     ports.UserDeletionRepository
+	users    ports.UserRepository
+	bikes    ports.BikeServicePort
+	logger   ports.LoggerPort
+	audit    ports.AuditPort
+	cache    *userLoader
+	settings DeletionSettings
+}
+
+func NewUserDeletionService(
+	repo ports.UserDeletionRepository,
+	users ports.UserRepository,
+	bikes ports.BikeServicePort,
+	logger ports.LoggerPort,
+	audit ports.AuditPort,
+	cache ports.CachePort,
+	cacheSettings CacheSettings,
+	settings DeletionSettings,
+) *UserDeletionService {
+	return &UserDeletionService{
+		repo:     repo,
+		users:    users,
+		bikes:    bikes,
+		logger:   logger,
+		audit:    audit,
+		cache:    newUserLoader(cache, logger, cacheSettings),
+		settings: settings,
+	}
+}
+
+func (s *UserDeletionService) StartDeletion(ctx context.Context, user *domain.User) (_ *domain.UserDeletion, err error) {
+	ctx, span := startSpan(ctx, "UserDeletionService.StartDeletion")
+	defer func() { endSpan(span, err) }()
+
+	var requestedBy *uuid.UUID
+	if payload, ok := requestctx.Payload(ctx); ok {
+		subject := payload.Subject()
+		requestedBy = &subject
+	}
+
+	deletion, err := s.repo.StartDeletion(ctx, user.ID, requestedBy, s.settings.Lease)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditUserDeletionRequested,
+		TargetID: &user.ID,
+		Details: map[string]string{
+			"deletion_id": deletion.ID.String(),
+		},
+	})
+	return deletion, nil
+}
+
+func (s *UserDeletionService) Advance(ctx context.Context, deletion *domain.UserDeletion) {
+	ctx, span := startSpan(ctx, "UserDeletionService.Advance")
+	defer span.End()
+
+	// The caller waits for one quick try at most, retries and slow calls are
+	// left to the worker once the lease runs out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.settings.AdvanceTimeout)
+	defer cancel()
+	s.advance(ctx, deletion)
+}
+
+func (s *UserDeletionService) GetDeletion(ctx context.Context, id string) (_ *domain.UserDeletion, err error) {
+	ctx, span := startSpan(ctx, "UserDeletionService.GetDeletion")
+	defer func() { endSpan(span, err) }()
+
+	deletionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
+	}
+	return s.repo.GetDeletion(ctx, deletionID)
+}
+
+func (s *UserDeletionService) ListDeletions(ctx context.Context, filter domain.DeletionFilter) (_ []domain.UserDeletion, _ int, err error) {
+	ctx, span := startSpan(ctx, "UserDeletionService.ListDeletions")
+	defer func() { endSpan(span, err) }()
+
+	switch filter.Status {
+	case "", domain.DeletionPending, domain.DeletionBikesRemoved, domain.DeletionCompleted, domain.DeletionCompensated:
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown status %s", domain.ErrValidation, filter.Status)
+	}
+	return s.repo.ListDeletions(ctx, filter.Normalized())
+}
+
+// Run resumes unfinished deletions until ctx is cancelled
+func (s *UserDeletionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.settings.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		deletions, err := s.repo.ClaimDueDeletions(ctx, s.settings.BatchSize, s.settings.Lease)
+		if err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "Failed to claim user deletions", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		for i := range deletions {
+			s.advance(ctx, &deletions[i])
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// advance runs the remaining steps of deletion, saving the state after each
+func (s *UserDeletionService) advance(ctx context.Context, deletion *domain.UserDeletion) {
+	if deletion.Status == domain.DeletionPending {
+		err := s.bikes.DeleteUserBikes(ctx, deletion.UserID)
+		if ctx.Err() != nil {
+			// Shutting down, the lease expires and another attempt follows
+			return
+		}
+		switch {
+		case err == nil:
+			deletion.Status = domain.DeletionBikesRemoved
+			deletion.Attempts = 0
+			deletion.LastError = ""
+			if err := s.repo.SaveDeletion(ctx, deletion, domain.DeletionPending); err != nil {
+				s.logSaveError(ctx, deletion, err)
+				return
+			}
+		case errors.Is(err, domain.ErrBikeServiceUnavailable) && deletion.Attempts+1 < s.settings.MaxAttempts:
+			s.retryLater(ctx, deletion, err)
+			return
+		default:
+			s.compensate(ctx, deletion, err)
+			return
+		}
+	}
+
+	if deletion.Status == domain.DeletionBikesRemoved {
+		s.finalize(ctx, deletion)
+	}
+}
+
+func (s *UserDeletionService) finalize(ctx context.Context, deletion *domain.UserDeletion) {
+	// Read before the row is gone, to clear the email from the cache
+	user, err := s.users.GetUserByID(requestctx.WithPrimary(ctx), deletion.UserID)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		s.retryLater(ctx, deletion, err)
+		return
+	}
+
+	now := time.Now()
+	deletion.Status = domain.DeletionCompleted
+	deletion.LastError = ""
+	deletion.CompletedAt = &now
+	if err := s.repo.FinalizeDeletion(ctx, deletion, domain.DeletionBikesRemoved); err != nil {
+		deletion.Status = domain.DeletionBikesRemoved
+		deletion.CompletedAt = nil
+		if errors.Is(err, domain.ErrDeletionConflict) {
+			s.logSaveError(ctx, deletion, err)
+			return
+		}
+		s.retryLater(ctx, deletion, err)
+		return
+	}
+
+	if user != nil {
+		s.invalidate(ctx, user)
+	}
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditUserDeleted,
+		ActorID:  deletion.RequestedBy,
+		TargetID: &deletion.UserID,
+		Changes:  userChanges(user, nil),
+		Details: map[string]string{
+			"deletion_id": deletion.ID.String(),
+		},
+	})
+	s.logger.InfoContext(ctx, "User deleted", map[string]interface{}{
+		"id":          deletion.UserID.String(),
+		"deletion_id": deletion.ID.String(),
+	})
+}
+
+func (s *UserDeletionService) compensate(ctx context.Context, deletion *domain.UserDeletion, cause error) {
+	deletion.Status = domain.DeletionCompensated
+	deletion.Attempts++
+	deletion.LastError = cause.Error()
+	if err := s.repo.CompensateDeletion(ctx, deletion, domain.DeletionPending); err != nil {
+		// Still pending, the next attempt compensates again if the bike service keeps failing
+		deletion.Status = domain.DeletionPending
+		if errors.Is(err, domain.ErrDeletionConflict) {
+			s.logSaveError(ctx, deletion, err)
+			return
+		}
+		s.logStepError(ctx, deletion, "Failed to compensate user deletion", err)
+		return
+	}
+
+	if user, err := s.users.GetUserByID(requestctx.WithPrimary(ctx), deletion.UserID); err == nil {
+		s.invalidate(ctx, user)
+		s.cache.store(ctx, user)
+	}
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditUserDeletionCompensated,
+		ActorID:  deletion.RequestedBy,
+		TargetID: &deletion.UserID,
+		Details: map[string]string{
+			"deletion_id": deletion.ID.String(),
+			"reason":      cause.Error(),
+		},
+	})
+	s.logger.WarnContext(ctx, "User deletion compensated", map[string]interface{}{
+		"id":          deletion.UserID.String(),
+		"deletion_id": deletion.ID.String(),
+		"error":       cause.Error(),
+	})
+}
+
+func (s *UserDeletionService) retryLater(ctx context.Context, deletion *domain.UserDeletion, cause error) {
+	deletion.Attempts++
+	deletion.LastError = cause.Error()
+	deletion.NextAttemptAt = time.Now().Add(s.backoff(deletion.Attempts))
+
+	s.logStepError(ctx, deletion, "User deletion step failed, will retry", cause)
+	if err := s.repo.SaveDeletion(ctx, deletion, deletion.Status); err != nil {
+		s.logSaveError(ctx, deletion, err)
+	}
+}
+
+// backoff is the delay after the given number of failed attempts
+func (s *UserDeletionService) backoff(attempts int) time.Duration {
+	delay := s.settings.BaseBackoff
+	for i := 1; i < attempts && delay < s.settings.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.settings.MaxBackoff)
+}
+
+func (s *UserDeletionService) logStepError(ctx context.Context, deletion *domain.UserDeletion, msg string, err error) {
+	s.logger.ErrorContext(ctx, msg, map[string]interface{}{
+		"deletion_id": deletion.ID.String(),
+		"status":      string(deletion.Status),
+		"attempts":    deletion.Attempts,
+		"error":       err.Error(),
+	})
+}
+
+// logSaveError stays quiet about a worker that lost the race, the winner carries on
+func (s *UserDeletionService) logSaveError(ctx context.Context, deletion *domain.UserDeletion, err error) {
+	if errors.Is(err, domain.ErrDeletionConflict) {
+		s.logger.InfoContext(ctx, "User deletion advanced by another worker", map[string]interface{}{
+			"deletion_id": deletion.ID.String(),
+			"status":      string(deletion.Status),
+		})
+		return
+	}
+	s.logStepError(ctx, deletion, "Failed to save user deletion", err)
+}
+
+func (s *UserDeletionService) invalidate(ctx context.Context, user *domain.User) {
+	s.cache.invalidateUser(ctx, user.ID.String(),
+		fmt.Sprintf("user:%s", user.ID.String()),
+		fmt.Sprintf("user_email:%s", user.Email),
+	)
+}
+
+var _ ports.UserDeletionService = (*UserDeletionService)(nil)
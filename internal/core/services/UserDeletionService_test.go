@@ -0,0 +1,447 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// fakeDeletionStore keeps users and deletions in memory and honours
+// the compare-and-set contract of ports.UserDeletionRepository
+type fakeDeletionStore struct {
+	ports.UserRepository
+
+	mu        sync.Mutex
+	users     map[uuid.UUID]domain.User
+	deletions map[uuid.UUID]domain.UserDeletion
+}
+
+func newFakeDeletionStore(users ...domain.User) *fakeDeletionStore {
+	s := &fakeDeletionStore{
+		users:     map[uuid.UUID]domain.User{},
+		deletions: map[uuid.UUID]domain.UserDeletion{},
+	}
+	for _, user := range users {
+		s.users[user.ID] = user
+	}
+	return s
+}
+
+func (s *fakeDeletionStore) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (s *fakeDeletionStore) StartDeletion(ctx context.Context, userID uuid.UUID, requestedBy *uuid.UUID, lease time.Duration) (*domain.UserDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	if user.Status != domain.UserActive {
+		return nil, domain.ErrDeletionInProgress
+	}
+	user.Status = domain.UserPendingDeletion
+	s.users[userID] = user
+
+	now := time.Now()
+	deletion := domain.UserDeletion{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Status:        domain.DeletionPending,
+		NextAttemptAt: now.Add(lease),
+		RequestedBy:   requestedBy,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.deletions[deletion.ID] = deletion
+	return &deletion, nil
+}
+
+func (s *fakeDeletionStore) GetDeletion(ctx context.Context, id uuid.UUID) (*domain.UserDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion, ok := s.deletions[id]
+	if !ok {
+		return nil, domain.ErrDeletionNotFound
+	}
+	return &deletion, nil
+}
+
+func (s *fakeDeletionStore) ListDeletions(ctx context.Context, filter domain.DeletionFilter) ([]domain.UserDeletion, int, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (s *fakeDeletionStore) ClaimDueDeletions(ctx context.Context, limit int, lease time.Duration) ([]domain.UserDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	deletions := []domain.UserDeletion{}
+	for id, deletion := range s.deletions {
+		if len(deletions) == limit {
+			break
+		}
+		if deletion.Finished() || deletion.NextAttemptAt.After(now) {
+			continue
+		}
+		deletion.NextAttemptAt = now.Add(lease)
+		s.deletions[id] = deletion
+		deletions = append(deletions, deletion)
+	}
+	return deletions, nil
+}
+
+func (s *fakeDeletionStore) SaveDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save(deletion, from)
+}
+
+func (s *fakeDeletionStore) FinalizeDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.save(deletion, from); err != nil {
+		return err
+	}
+	delete(s.users, deletion.UserID)
+	return nil
+}
+
+func (s *fakeDeletionStore) CompensateDeletion(ctx context.Context, deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.save(deletion, from); err != nil {
+		return err
+	}
+	if user, ok := s.users[deletion.UserID]; ok && user.Status == domain.UserPendingDeletion {
+		user.Status = domain.UserActive
+		s.users[deletion.UserID] = user
+	}
+	return nil
+}
+
+func (s *fakeDeletionStore) save(deletion *domain.UserDeletion, from domain.DeletionStatus) error {
+	stored, ok := s.deletions[deletion.ID]
+	if !ok {
+		return domain.ErrDeletionNotFound
+	}
+	if stored.Status != from {
+		return domain.ErrDeletionConflict
+	}
+	s.deletions[deletion.ID] = *deletion
+	return nil
+}
+
+func (s *fakeDeletionStore) deletion(t *testing.T, id uuid.UUID) domain.UserDeletion {
+	t.Helper()
+
+	deletion, err := s.GetDeletion(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetDeletion: %v", err)
+	}
+	return *deletion
+}
+
+func (s *fakeDeletionStore) user(id uuid.UUID) (domain.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	return user, ok
+}
+
+// fakeBikes answers DeleteUserBikes with the queued errors, then with nil.
+// While hang is set calls wait for their context instead
+type fakeBikes struct {
+	mu      sync.Mutex
+	errs    []error
+	deletes int
+	hang    bool
+}
+
+func (b *fakeBikes) GetUserBikes(ctx context.Context, token string) ([]domain.Bike, error) {
+	return []domain.Bike{}, nil
+}
+
+func (b *fakeBikes) DeleteUserBikes(ctx context.Context, userID uuid.UUID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deletes++
+	if b.hang {
+		b.mu.Unlock()
+		<-ctx.Done()
+		b.mu.Lock()
+		return ctx.Err()
+	}
+	if len(b.errs) == 0 {
+		return nil
+	}
+	err := b.errs[0]
+	b.errs = b.errs[1:]
+	return err
+}
+
+func (b *fakeBikes) calls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.deletes
+}
+
+type fakeAudit struct {
+	mu      sync.Mutex
+	actions []domain.AuditAction
+}
+
+func (a *fakeAudit) Record(ctx context.Context, event *domain.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.actions = append(a.actions, event.Action)
+}
+
+func (a *fakeAudit) ListEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	return nil, 0, nil
+}
+
+func (a *fakeAudit) recorded(action domain.AuditAction) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, recorded := range a.actions {
+		if recorded == action {
+			return true
+		}
+	}
+	return false
+}
+
+var testDeletionSettings = DeletionSettings{
+	MaxAttempts:    3,
+	BaseBackoff:    time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+	PollInterval:   5 * time.Millisecond,
+	BatchSize:      10,
+	Lease:          time.Minute,
+	AdvanceTimeout: time.Second,
+}
+
+func newTestDeletionService(store *fakeDeletionStore, bikes *fakeBikes, audit *fakeAudit) *UserDeletionService {
+	return NewUserDeletionService(store, store, bikes, testutil.NopLogger{}, audit, testutil.NopCache{}, CacheSettings{}, testDeletionSettings)
+}
+
+func newTestUser() domain.User {
+	return domain.User{
+		ID:     uuid.New(),
+		Email:  "rider@example.com",
+		Status: domain.UserActive,
+	}
+}
+
+func TestUserDeletionService_AdvanceCompletes(t *testing.T) {
+	user := newTestUser()
+	store := newFakeDeletionStore(user)
+	bikes := &fakeBikes{}
+	audit := &fakeAudit{}
+	service := newTestDeletionService(store, bikes, audit)
+	ctx := context.Background()
+
+	deletion, err := service.StartDeletion(ctx, &user)
+	if err != nil {
+		t.Fatalf("StartDeletion: %v", err)
+	}
+	if !deletion.NextAttemptAt.After(time.Now()) {
+		t.Errorf("new deletion is due at %v, want it leased to the caller", deletion.NextAttemptAt)
+	}
+
+	service.Advance(ctx, deletion)
+
+	stored := store.deletion(t, deletion.ID)
+	if stored.Status != domain.DeletionCompleted {
+		t.Fatalf("status = %s, want %s", stored.Status, domain.DeletionCompleted)
+	}
+	if stored.CompletedAt == nil {
+		t.Error("CompletedAt is not set")
+	}
+	if _, ok := store.user(user.ID); ok {
+		t.Error("user still exists")
+	}
+	if bikes.calls() != 1 {
+		t.Errorf("DeleteUserBikes called %d times, want 1", bikes.calls())
+	}
+	if !audit.recorded(domain.AuditUserDeleted) {
+		t.Errorf("audit event %s not recorded", domain.AuditUserDeleted)
+	}
+}
+
+func TestUserDeletionService_AdvanceGivesUpAfterTimeout(t *testing.T) {
+	user := newTestUser()
+	store := newFakeDeletionStore(user)
+	bikes := &fakeBikes{hang: true}
+	settings := testDeletionSettings
+	settings.AdvanceTimeout = 20 * time.Millisecond
+	service := NewUserDeletionService(store, store, bikes, testutil.NopLogger{}, &fakeAudit{}, testutil.NopCache{}, CacheSettings{}, settings)
+	ctx := context.Background()
+
+	deletion, err := service.StartDeletion(ctx, &user)
+	if err != nil {
+		t.Fatalf("StartDeletion: %v", err)
+	}
+
+	start := time.Now()
+	service.Advance(ctx, deletion)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Advance took %s, want it to stop after %s", elapsed, settings.AdvanceTimeout)
+	}
+
+	// Left pending under the lease, the worker resumes it once the lease runs out
+	stored := store.deletion(t, deletion.ID)
+	if stored.Status != domain.DeletionPending || stored.Attempts != 0 {
+		t.Errorf("deletion = %s after %d attempts, want pending and untouched", stored.Status, stored.Attempts)
+	}
+	if !stored.NextAttemptAt.After(time.Now()) {
+		t.Errorf("deletion is due at %v, want it still leased", stored.NextAttemptAt)
+	}
+}
+
+func TestUserDeletionService_RetriesThenCompensates(t *testing.T) {
+	user := newTestUser()
+	store := newFakeDeletionStore(user)
+	bikes := &fakeBikes{errs: []error{
+		domain.ErrBikeServiceUnavailable,
+		domain.ErrBikeServiceUnavailable,
+		domain.ErrBikeServiceUnavailable,
+	}}
+	audit := &fakeAudit{}
+	service := newTestDeletionService(store, bikes, audit)
+	ctx := context.Background()
+
+	deletion, err := service.StartDeletion(ctx, &user)
+	if err != nil {
+		t.Fatalf("StartDeletion: %v", err)
+	}
+
+	for attempt := 1; attempt < testDeletionSettings.MaxAttempts; attempt++ {
+		service.Advance(ctx, deletion)
+
+		stored := store.deletion(t, deletion.ID)
+		if stored.Status != domain.DeletionPending {
+			t.Fatalf("attempt %d: status = %s, want %s", attempt, stored.Status, domain.DeletionPending)
+		}
+		if stored.Attempts != attempt {
+			t.Fatalf("attempt %d: attempts = %d", attempt, stored.Attempts)
+		}
+		if stored.LastError == "" {
+			t.Fatalf("attempt %d: LastError is empty", attempt)
+		}
+		deletion = &stored
+	}
+
+	service.Advance(ctx, deletion)
+
+	stored := store.deletion(t, deletion.ID)
+	if stored.Status != domain.DeletionCompensated {
+		t.Fatalf("status = %s, want %s", stored.Status, domain.DeletionCompensated)
+	}
+	got, ok := store.user(user.ID)
+	if !ok {
+		t.Fatal("compensated user was deleted")
+	}
+	if got.Status != domain.UserActive {
+		t.Errorf("user status = %s, want %s", got.Status, domain.UserActive)
+	}
+	if bikes.calls() != testDeletionSettings.MaxAttempts {
+		t.Errorf("DeleteUserBikes called %d times, want %d", bikes.calls(), testDeletionSettings.MaxAttempts)
+	}
+	if !audit.recorded(domain.AuditUserDeletionCompensated) {
+		t.Errorf("audit event %s not recorded", domain.AuditUserDeletionCompensated)
+	}
+}
+
+func TestUserDeletionService_RunResumesBikesRemoved(t *testing.T) {
+	user := newTestUser()
+	user.Status = domain.UserPendingDeletion
+	store := newFakeDeletionStore(user)
+
+	// Left behind by a replica that stopped right after the bikes were removed
+	deletion := domain.UserDeletion{
+		ID:            uuid.New(),
+		UserID:        user.ID,
+		Status:        domain.DeletionBikesRemoved,
+		NextAttemptAt: time.Now().Add(-time.Second),
+	}
+	store.deletions[deletion.ID] = deletion
+
+	bikes := &fakeBikes{}
+	service := newTestDeletionService(store, bikes, &fakeAudit{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.deletion(t, deletion.ID).Status != domain.DeletionCompleted {
+		if time.Now().After(deadline) {
+			cancel()
+			<-done
+			t.Fatalf("deletion not completed, status = %s", store.deletion(t, deletion.ID).Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if _, ok := store.user(user.ID); ok {
+		t.Error("user still exists")
+	}
+	if bikes.calls() != 0 {
+		t.Errorf("DeleteUserBikes called %d times after the bikes were removed", bikes.calls())
+	}
+}
+
+func TestUserDeletionService_LostRaceLeavesDeletionAlone(t *testing.T) {
+	user := newTestUser()
+	store := newFakeDeletionStore(user)
+	bikes := &fakeBikes{}
+	service := newTestDeletionService(store, bikes, &fakeAudit{})
+	ctx := context.Background()
+
+	deletion, err := service.StartDeletion(ctx, &user)
+	if err != nil {
+		t.Fatalf("StartDeletion: %v", err)
+	}
+	stale := *deletion
+
+	// Another worker finished it first
+	service.Advance(ctx, deletion)
+	winner := store.deletion(t, deletion.ID)
+
+	service.Advance(ctx, &stale)
+
+	if got := store.deletion(t, deletion.ID); got != winner {
+		t.Errorf("stale worker overwrote the deletion: got %+v, want %+v", got, winner)
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// How long users are cached by ID
+const userCacheTTL = 15 * time.Minute
+
+type CacheSettings struct {
+	// How long unknown IDs and emails are remembered
+	NegativeTTL time.Duration
+	// XFetch beta for probabilistic early refresh, 0 disables it
+	EarlyRefreshBeta float64
+	// How long bike lists from the bike service are reused
+	BikesTTL time.Duration
+}
+
+// userLoader reads users through the cache.
+// Concurrent misses for one key share a single repository call,
+// unknown keys are cached as not found, and hot keys may be refreshed
+// shortly before they expire so they never fall through all at once
+type userLoader struct {
+	cache    ports.CachePort
+	logger   ports.LoggerPort
+	settings CacheSettings
+	group    singleflight.Group
+
+	// Replaced in tests so early refreshes are deterministic
+	now    func() time.Time
+	random func() float64
+}
+
+// Cache entry, Delta is how long the repository call took
+type cachedUser struct {
+	User      *domain.User  `json:"user,omitempty"`
+	NotFound  bool          `json:"not_found,omitempty"`
+	Delta     time.Duration `json:"delta"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+func newUserLoader(cache ports.CachePort, logger ports.LoggerPort, settings CacheSettings) *userLoader {
+	return &userLoader{
+		cache:    cache,
+		logger:   logger,
+		settings: settings,
+		now:      time.Now,
+		random:   rand.Float64,
+	}
+}
+
+// load returns domain.ErrUserNotFound when fetch found nothing, now or recently
+func (l *userLoader) load(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) (*domain.User, error),
+) (*domain.User, error) {
+	entry, ok := l.get(ctx, key)
+	if ok {
+		if entry.NotFound {
+			return nil, domain.ErrUserNotFound
+		}
+		if !l.refreshEarly(entry) {
+			return entry.User, nil
+		}
+	}
+
+	result := l.group.DoChan(key, func() (interface{}, error) {
+		// Shared by all waiters, so one cancelled request must not fail the others
+		return l.fetch(context.WithoutCancel(ctx), key, ttl, fetch)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			// Early refresh failed, the cached user is still valid
+			if ok && !errors.Is(res.Err, domain.ErrUserNotFound) {
+				return entry.User, nil
+			}
+			return nil, res.Err
+		}
+		return res.Val.(*domain.User), nil
+	}
+}
+
+func (l *userLoader) fetch(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) (*domain.User, error),
+) (*domain.User, error) {
+	start := l.now()
+	user, err := fetch(ctx)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+
+	entry := cachedUser{
+		User:  user,
+		Delta: l.now().Sub(start),
+	}
+	if user == nil {
+		entry.NotFound = true
+		ttl = l.settings.NegativeTTL
+	}
+	entry.ExpiresAt = l.now().Add(ttl)
+
+	if ttl > 0 {
+		l.set(ctx, key, entry, ttl)
+	}
+
+	if entry.NotFound {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// loadMany is load for a batch of IDs: one MGet, one fetch for the misses, one MSet.
+// Unknown IDs are absent from the result
+func (l *userLoader) loadMany(
+	ctx context.Context,
+	ids []uuid.UUID,
+	ttl time.Duration,
+	fetch func(ctx context.Context, ids []uuid.UUID) ([]domain.User, error),
+) (map[uuid.UUID]*domain.User, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, userCacheKey(id))
+	}
+
+	cached, err := l.cache.MGet(ctx, keys...)
+	if err != nil {
+		l.logger.WarnContext(ctx, "Failed to read users from cache", map[string]interface{}{
+			"error": err.Error(),
+			"count": len(keys),
+		})
+	}
+
+	users := make(map[uuid.UUID]*domain.User, len(ids))
+	var misses []uuid.UUID
+	for i, id := range ids {
+		var entry cachedUser
+		data, ok := cached[keys[i]]
+		if !ok || json.Unmarshal(data, &entry) != nil || (entry.User == nil && !entry.NotFound) {
+			misses = append(misses, id)
+			continue
+		}
+		if entry.User != nil {
+			users[id] = entry.User
+		}
+	}
+	if len(misses) == 0 {
+		return users, nil
+	}
+
+	start := l.now()
+	found, err := fetch(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	delta := l.now().Sub(start)
+
+	for i := range found {
+		users[found[i].ID] = &found[i]
+	}
+
+	items := make([]ports.CacheItem, 0, len(misses))
+	for _, id := range misses {
+		entry := cachedUser{User: users[id], Delta: delta}
+		itemTTL := ttl
+		var tags []string
+		if entry.User == nil {
+			entry.NotFound = true
+			itemTTL = l.settings.NegativeTTL
+		} else {
+			tags = append(tags, userCacheTag(id.String()))
+		}
+		if itemTTL <= 0 {
+			continue
+		}
+		entry.ExpiresAt = l.now().Add(itemTTL)
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		items = append(items, ports.CacheItem{Key: userCacheKey(id), Value: data, TTL: itemTTL, Tags: tags})
+	}
+	if err := l.cache.MSet(ctx, items...); err != nil {
+		l.logger.WarnContext(ctx, "Failed to cache users", map[string]interface{}{
+			"error": err.Error(),
+			"count": len(items),
+		})
+	}
+	return users, nil
+}
+
+// invalidateUser drops every entry of the user, whatever key it was cached under,
+// plus the given keys which may hold negative entries
+func (l *userLoader) invalidateUser(ctx context.Context, id string, keys ...string) {
+	if err := l.cache.InvalidateTags(ctx, userCacheTag(id)); err != nil {
+		l.logger.WarnContext(ctx, "Failed to invalidate user cache", map[string]interface{}{
+			"error": err.Error(),
+			"id":    id,
+		})
+	}
+
+	l.invalidate(ctx, keys...)
+}
+
+func (l *userLoader) invalidate(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	if err := l.cache.DeleteMany(ctx, keys...); err != nil {
+		l.logger.WarnContext(ctx, "Failed to invalidate user cache keys", map[string]interface{}{
+			"error": err.Error(),
+			"count": len(keys),
+		})
+	}
+}
+
+func (l *userLoader) get(ctx context.Context, key string) (cachedUser, bool) {
+	var entry cachedUser
+
+	data, err := l.cache.Get(ctx, key)
+	if err != nil {
+		return entry, false
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	// Entries written in the old format carry neither field
+	if entry.User == nil && !entry.NotFound {
+		return entry, false
+	}
+	return entry, true
+}
+
+// store caches user right after it was written, otherwise the next read misses
+// and may fill the cache from a replica that has not caught up yet
+func (l *userLoader) store(ctx context.Context, user *domain.User) {
+	l.set(ctx, userCacheKey(user.ID), cachedUser{
+		User:      user,
+		ExpiresAt: l.now().Add(userCacheTTL),
+	}, userCacheTTL)
+}
+
+func (l *userLoader) set(ctx context.Context, key string, entry cachedUser, ttl time.Duration) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		l.logger.WarnContext(ctx, "Failed to marshal user for cache", map[string]interface{}{
+			"error": err.Error(),
+			"key":   key,
+		})
+		return
+	}
+
+	var tags []string
+	if entry.User != nil {
+		tags = append(tags, userCacheTag(entry.User.ID.String()))
+	}
+
+	if err := l.cache.Set(ctx, key, data, ttl, tags...); err != nil {
+		l.logger.WarnContext(ctx, "Failed to cache user", map[string]interface{}{
+			"error": err.Error(),
+			"key":   key,
+		})
+	}
+}
+
+// XFetch: the closer the entry is to expiry and the slower it is to load,
+// the more likely a request recomputes it ahead of time
+func (l *userLoader) refreshEarly(entry cachedUser) bool {
+	if l.settings.EarlyRefreshBeta <= 0 || entry.ExpiresAt.IsZero() {
+		return false
+	}
+
+	gap := -float64(entry.Delta) * l.settings.EarlyRefreshBeta * math.Log(1-l.random())
+	return l.now().Add(time.Duration(gap)).After(entry.ExpiresAt)
+}
+
+func userCacheKey(id uuid.UUID) string {
+	return fmt.Sprintf("user:%s", id.String())
+}
+
+// All entries of one user share this tag
+func userCacheTag(id string) string {
+	return fmt.Sprintf("user:%s", id)
+}
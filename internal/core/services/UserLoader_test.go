@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// mapCache keeps entries forever, the loader is tested against its own expiry bookkeeping
+type mapCache struct {
+	testutil.NopCache
+
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+	gets    int
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{
+		entries: make(map[string][]byte),
+		ttls:    make(map[string]time.Duration),
+	}
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	data, ok := c.entries[key]
+	if !ok {
+		return nil, ports.ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (c *mapCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = value
+	c.ttls[key] = ttl
+	return nil
+}
+
+func (c *mapCache) getCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gets
+}
+
+func (c *mapCache) ttl(key string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ttl, ok := c.ttls[key]
+	return ttl, ok
+}
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// newTestUserLoader runs on a stopped clock at testNow and draws random from r
+func newTestUserLoader(cache ports.CachePort, settings CacheSettings, r float64) *userLoader {
+	l := newUserLoader(cache, testutil.NopLogger{}, settings)
+	l.now = func() time.Time { return testNow }
+	l.random = func() float64 { return r }
+	return l
+}
+
+// countingFetch returns user, or domain.ErrUserNotFound when it is nil
+func countingFetch(user *domain.User, calls *atomic.Int32) func(ctx context.Context) (*domain.User, error) {
+	return func(ctx context.Context) (*domain.User, error) {
+		calls.Add(1)
+		if user == nil {
+			return nil, domain.ErrUserNotFound
+		}
+		return user, nil
+	}
+}
+
+func TestUserLoader_ConcurrentMissesShareFetch(t *testing.T) {
+	const waiters = 8
+
+	cache := newMapCache()
+	l := newTestUserLoader(cache, CacheSettings{}, 0)
+	user := &domain.User{ID: uuid.New(), Name: "Иван"}
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fetch := func(ctx context.Context) (*domain.User, error) {
+		calls.Add(1)
+		<-release
+		return user, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cancelledCtx, cancelWaiter := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	results := make([]*domain.User, waiters)
+	errs := make([]error, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			waiterCtx := ctx
+			if i == 0 {
+				waiterCtx = cancelledCtx
+			}
+			results[i], errs[i] = l.load(waiterCtx, userCacheKey(user.ID), userCacheTTL, fetch)
+		}(i)
+	}
+
+	// Every waiter has missed the cache, give them a moment to join the flight
+	for cache.getCount() < waiters {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// A waiter giving up must not fail the fetch shared with the others
+	cancelWaiter()
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fetch ran %d times, want once", got)
+	}
+	if !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("cancelled waiter err = %v, want context.Canceled", errs[0])
+	}
+	for i := 1; i < waiters; i++ {
+		if errs[i] != nil || results[i] != user {
+			t.Errorf("waiter %d = %v, %v, want the fetched user", i, results[i], errs[i])
+		}
+	}
+}
+
+func TestUserLoader_NegativeCaching(t *testing.T) {
+	tests := []struct {
+		name        string
+		negativeTTL time.Duration
+		wantCalls   int32
+	}{
+		{name: "remembers unknown keys", negativeTTL: time.Minute, wantCalls: 1},
+		{name: "disabled", negativeTTL: 0, wantCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMapCache()
+			l := newTestUserLoader(cache, CacheSettings{NegativeTTL: tt.negativeTTL}, 0)
+			key := userCacheKey(uuid.New())
+
+			var calls atomic.Int32
+			for i := 0; i < 3; i++ {
+				_, err := l.load(context.Background(), key, userCacheTTL, countingFetch(nil, &calls))
+				if !errors.Is(err, domain.ErrUserNotFound) {
+					t.Fatalf("load %d: err = %v, want domain.ErrUserNotFound", i+1, err)
+				}
+			}
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("fetch ran %d times, want %d", got, tt.wantCalls)
+			}
+			ttl, cached := cache.ttl(key)
+			if cached != (tt.negativeTTL > 0) || (cached && ttl != tt.negativeTTL) {
+				t.Errorf("cached = %v with ttl %s, want ttl %s", cached, ttl, tt.negativeTTL)
+			}
+		})
+	}
+}
+
+func TestUserLoader_RefreshEarly(t *testing.T) {
+	// Makes -ln(1-r) exactly 1, so the refresh gap is delta * beta
+	gapOfOne := 1 - math.Exp(-1)
+
+	tests := []struct {
+		name      string
+		beta      float64
+		random    float64
+		delta     time.Duration
+		expiresIn time.Duration
+		unset     bool
+		want      bool
+	}{
+		{name: "disabled", beta: 0, random: gapOfOne, delta: time.Second, expiresIn: time.Millisecond},
+		{name: "no expiry recorded", beta: 1, random: gapOfOne, delta: time.Second, unset: true},
+		{name: "gap reaches expiry", beta: 1, random: gapOfOne, delta: 100 * time.Millisecond, expiresIn: 50 * time.Millisecond, want: true},
+		{name: "gap short of expiry", beta: 1, random: gapOfOne, delta: 100 * time.Millisecond, expiresIn: 150 * time.Millisecond},
+		{name: "beta widens the gap", beta: 2, random: gapOfOne, delta: 100 * time.Millisecond, expiresIn: 150 * time.Millisecond, want: true},
+		{name: "fast loads refresh late", beta: 1, random: gapOfOne, delta: time.Millisecond, expiresIn: 50 * time.Millisecond},
+		{name: "zero draw never refreshes early", beta: 1, random: 0, delta: time.Hour, expiresIn: time.Millisecond},
+		{name: "expired entries refresh", beta: 1, random: 0, delta: time.Millisecond, expiresIn: -time.Millisecond, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestUserLoader(newMapCache(), CacheSettings{EarlyRefreshBeta: tt.beta}, tt.random)
+			entry := cachedUser{Delta: tt.delta}
+			if !tt.unset {
+				entry.ExpiresAt = testNow.Add(tt.expiresIn)
+			}
+
+			if got := l.refreshEarly(entry); got != tt.want {
+				t.Errorf("refreshEarly = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserLoader_LoadRefreshesEarly(t *testing.T) {
+	stale := &domain.User{ID: uuid.New(), Name: "Старое имя"}
+	fresh := &domain.User{ID: stale.ID, Name: "Новое имя"}
+	errDB := errors.New("database is down")
+
+	tests := []struct {
+		name      string
+		random    float64
+		fetchErr  error
+		wantUser  *domain.User
+		wantCalls int32
+	}{
+		{name: "serves the cached user", random: 0, wantUser: stale, wantCalls: 0},
+		{name: "refreshes ahead of expiry", random: 0.99, wantUser: fresh, wantCalls: 1},
+		{name: "failed refresh keeps the cached user", random: 0.99, fetchErr: errDB, wantUser: stale, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMapCache()
+			key := userCacheKey(stale.ID)
+			l := newTestUserLoader(cache, CacheSettings{EarlyRefreshBeta: 1}, tt.random)
+			l.set(context.Background(), key, cachedUser{
+				User:      stale,
+				Delta:     time.Second,
+				ExpiresAt: testNow.Add(time.Second),
+			}, time.Second)
+
+			var calls atomic.Int32
+			got, err := l.load(context.Background(), key, userCacheTTL, func(ctx context.Context) (*domain.User, error) {
+				calls.Add(1)
+				if tt.fetchErr != nil {
+					return nil, tt.fetchErr
+				}
+				return fresh, nil
+			})
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+
+			if got.Name != tt.wantUser.Name {
+				t.Errorf("user = %q, want %q", got.Name, tt.wantUser.Name)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("fetch ran %d times, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -2,42 +2,73 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
 	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/requestctx"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/errgroup"
 )
 
+// Most IDs a single GetUsers call accepts
+const MaxBatchGetUsers = 100
+
+// Most users a single ImportUsers call accepts
+const MaxImportUsers = 1000
+
 type UserService struct {
-	repo     ports.UserRepository
-	logger   ports.LoggerPort
-	validate *validator.Validate
-	cache    ports.CachePort
+	repo      ports.UserRepository
+	deletions ports.UserDeletionService
+	tx        ports.TransactionManager
+	logger    ports.LoggerPort
+	metrics   ports.MetricsPort
+	audit     ports.AuditPort
+	validate  *validator.Validate
+	cache     *userLoader
+	bikes     *bikeLoader
 }
 
 func NewUserService(
 	repo ports.UserRepository,
+	deletions ports.UserDeletionService,
+	tx ports.TransactionManager,
 	logger ports.LoggerPort,
+	metrics ports.MetricsPort,
+	audit ports.AuditPort,
 	validate *validator.Validate,
 	cache ports.CachePort,
-
+	cacheSettings CacheSettings,
+	bikes ports.BikeServicePort,
 ) *UserService {
 	return &UserService{
-		repo:     repo,
-		logger:   logger,
-		validate: validate,
-		cache:    cache,
+		repo:      repo,
+		deletions: deletions,
+		tx:        tx,
+		logger:    logger,
+		metrics:   metrics,
+		audit:     audit,
+		validate:  validate,
+		cache:     newUserLoader(cache, logger, cacheSettings),
+		bikes:     newBikeLoader(bikes, cache, logger, cacheSettings.BikesTTL),
 	}
 }
 
-func (us *UserService) Register(ctx context.Context, user *domain.User) (*domain.User, error) {
+func (us *UserService) Register(ctx context.Context, user *domain.User) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.Register")
+	defer func() { endSpan(span, err) }()
+	defer func() { recordOutcome(us.metrics, ports.MetricRegistrations, err) }()
+
 	if err := us.validateUser(user); err != nil {
-		us.logger.Error("Validation failed", map[string]interface{}{
+		us.logger.ErrorContext(ctx, "Validation failed", map[string]interface{}{
 			"error":  err.Error(),
 			"method": "Register",
 		})
@@ -46,7 +77,7 @@ func (us *UserService) Register(ctx context.Context, user *domain.User) (*domain
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		us.logger.Error("Error during hashing", map[string]interface{}{
+		us.logger.ErrorContext(ctx, "Error during hashing", map[string]interface{}{
 			"error":  err.Error(),
 			"method": "Register",
 		})
@@ -55,74 +86,154 @@ func (us *UserService) Register(ctx context.Context, user *domain.User) (*domain
 
 	user.Password = string(hashedPassword)
 
-	user, err = us.repo.CreateUser(ctx, user)
+	var created *domain.User
+	err = us.tx.WithinTx(ctx, func(ctx context.Context) error {
+		created, err = us.repo.CreateUser(ctx, user)
+		if err != nil {
+			return err
+		}
+		us.audit.Record(ctx, &domain.AuditEvent{
+			Action:   domain.AuditUserRegistered,
+			ActorID:  &created.ID,
+			TargetID: &created.ID,
+			Changes:  userChanges(nil, created),
+		})
+		return nil
+	})
 	if err != nil {
-		us.logger.Error("Failed to create user in database", map[string]interface{}{
+		us.logger.ErrorContext(ctx, "Failed to create user in database", map[string]interface{}{
 			"error":  err.Error(),
 			"method": "Register",
 		})
 		return nil, err
 	}
-	return user, nil
+
+	// The email may be remembered as unknown from earlier login attempts
+	us.cache.invalidate(ctx, fmt.Sprintf("user_email:%s", created.Email))
+	us.cache.store(ctx, created)
+
+	return created, nil
 }
 
-func (us *UserService) GetUser(ctx context.Context, id string) (*domain.User, error) {
+func (us *UserService) GetUser(ctx context.Context, id string) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUser")
+	defer func() { endSpan(span, err) }()
+
 	userID, err := uuid.Parse(id)
 	if err != nil {
-		us.logger.Error("Invalid UUID format", map[string]interface{}{
+		us.logger.ErrorContext(ctx, "Invalid UUID format", map[string]interface{}{
 			"id":    id,
 			"error": err.Error(),
 		})
-		return nil, fmt.Errorf("invalid ID format: %w", err)
-	}
-
-	// Tries to take from cache
-	cacheKey := fmt.Sprintf("user:%s", id)
-	cachedData, err := us.cache.Get(cacheKey)
-	if err == nil {
-		var cachedUser domain.User
-		if err := json.Unmarshal(cachedData, &cachedUser); err == nil {
-			us.logger.Info("User found in cache", map[string]interface{}{
-				"id": id,
-			})
-			return &cachedUser, nil
-		}
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
 	}
 
-	// Going to db
-	user, err := us.repo.GetUserByID(ctx, userID)
+	// Cache first, concurrent misses share one query
+	cacheKey := fmt.Sprintf("user:%s", userID.String())
+	user, err := us.cache.load(ctx, cacheKey, userCacheTTL, func(ctx context.Context) (*domain.User, error) {
+		return us.repo.GetUserByID(ctx, userID)
+	})
 	if err != nil {
-		us.logger.Error("Failed to get user", map[string]interface{}{
+		us.logger.ErrorContext(ctx, "Failed to get user", map[string]interface{}{
 			"id":    id,
 			"error": err.Error(),
 		})
 		return nil, err
 	}
 
-	// Caching for 15 min
-	userData, err := json.Marshal(user)
+	return user, nil
+}
+
+// GetUsers keeps the order of ids, duplicates are returned once
+func (us *UserService) GetUsers(ctx context.Context, ids []string) (_ []domain.User, _ []string, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUsers")
+	defer func() { endSpan(span, err) }()
+
+	if len(ids) > MaxBatchGetUsers {
+		return nil, nil, fmt.Errorf("%w: at most %d ids are allowed", domain.ErrValidation, MaxBatchGetUsers)
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		userID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %w", domain.ErrInvalidID, id, err)
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return []domain.User{}, []string{}, nil
+	}
+
+	found, err := us.cache.loadMany(ctx, userIDs, userCacheTTL, us.repo.GetUsersByIDs)
 	if err != nil {
-		us.logger.Warn("Failed to marshal user for cache", map[string]interface{}{
+		us.logger.ErrorContext(ctx, "Failed to get users", map[string]interface{}{
+			"count": len(userIDs),
 			"error": err.Error(),
-			"id":    id,
 		})
-	} else {
-		if err := us.cache.Set(cacheKey, userData, 15*time.Minute); err != nil {
-			us.logger.Warn("Failed to cache user", map[string]interface{}{
-				"error": err.Error(),
-				"id":    id,
-			})
+		return nil, nil, err
+	}
+
+	users := make([]domain.User, 0, len(found))
+	missing := []string{}
+	for _, id := range userIDs {
+		if user, ok := found[id]; ok {
+			users = append(users, *user)
+		} else {
+			missing = append(missing, id.String())
 		}
 	}
+	return users, missing, nil
+}
 
-	return user, nil
+// GetUserWithBikes never fails because of the bike service,
+// the user is returned with a partial flag instead
+func (us *UserService) GetUserWithBikes(ctx context.Context, id string, token string) (_ *domain.UserWithBikes, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUserWithBikes")
+	defer func() { endSpan(span, err) }()
+
+	user, err := us.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.UserWithBikes{User: user}
+
+	// The bike service answers for the owner of the token only
+	payload, ok := requestctx.Payload(ctx)
+	if !ok || payload.IsService() || payload.UserID != user.ID {
+		result.Partial = true
+		result.PartialReason = "bikes are only available to their owner"
+		return result, nil
+	}
+
+	bikes, err := us.bikes.load(ctx, user.ID.String(), token)
+	if err != nil {
+		us.logger.WarnContext(ctx, "Failed to get bikes from Bike service", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": id,
+		})
+		result.Partial = true
+		result.PartialReason = "bike service unavailable"
+		return result, nil
+	}
+
+	result.Bikes = bikes
+	return result, nil
 }
 
-func (us *UserService) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+func (us *UserService) UpdateUser(ctx context.Context, user *domain.User) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.UpdateUser")
+	defer func() { endSpan(span, err) }()
+
 	if user.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 		if err != nil {
-			us.logger.Error("Error during hashing", map[string]interface{}{
+			us.logger.ErrorContext(ctx, "Error during hashing", map[string]interface{}{
 				"error":  err.Error(),
 				"method": "UpdateUser",
 			})
@@ -131,91 +242,285 @@ func (us *UserService) UpdateUser(ctx context.Context, user *domain.User) (*doma
 		user.Password = string(hashedPassword)
 	}
 
-	updatedUser, err := us.repo.UpdateUser(ctx, user)
-	if err != nil {
-		us.logger.Error("Failed to update user", map[string]interface{}{
-			"id":    user.ID,
-			"error": err.Error(),
+	var updatedUser *domain.User
+	err = us.tx.WithinTxIsolation(ctx, ports.IsolationRepeatableRead, func(ctx context.Context) error {
+		// Needed for the audit diff, read in the transaction so it is not behind the write
+		before, err := us.repo.GetUserByID(ctx, user.ID)
+		if err != nil {
+			us.logger.ErrorContext(ctx, "Failed to get user before update", map[string]interface{}{
+				"id":    user.ID,
+				"error": err.Error(),
+			})
+			return err
+		}
+		if before.Status == domain.UserPendingDeletion {
+			return domain.ErrDeletionInProgress
+		}
+
+		updatedUser, err = us.repo.UpdateUser(ctx, user)
+		if err != nil {
+			us.logger.ErrorContext(ctx, "Failed to update user", map[string]interface{}{
+				"id":    user.ID,
+				"error": err.Error(),
+			})
+			return err
+		}
+
+		us.audit.Record(ctx, &domain.AuditEvent{
+			Action:   domain.AuditUserUpdated,
+			TargetID: &updatedUser.ID,
+			Changes:  userChanges(before, updatedUser),
 		})
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	cacheKey := fmt.Sprintf("user:%s", user.ID.String())
-	if err := us.cache.Delete(cacheKey); err != nil {
-		us.logger.Warn("Failed to invalidate user cache", map[string]interface{}{
+	// Drops entries under the old email as well, the new one may be cached as unknown
+	us.cache.invalidateUser(ctx, user.ID.String(),
+		fmt.Sprintf("user:%s", user.ID.String()),
+		fmt.Sprintf("user_email:%s", updatedUser.Email),
+	)
+	us.cache.store(ctx, updatedUser)
+
+	return updatedUser, nil
+}
+
+// DeleteUser starts the deletion saga. The user is gone when the returned
+// deletion is completed, otherwise it finishes in the background
+func (us *UserService) DeleteUser(ctx context.Context, id string) (_ *domain.UserDeletion, err error) {
+	ctx, span := startSpan(ctx, "UserService.DeleteUser")
+	defer func() { endSpan(span, err) }()
+	defer func() { recordOutcome(us.metrics, ports.MetricDeletions, err) }()
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		us.logger.ErrorContext(ctx, "Invalid UUID format", map[string]interface{}{
+			"id":    id,
 			"error": err.Error(),
-			"id":    user.ID.String(),
 		})
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
 	}
 
-	emailCacheKey := fmt.Sprintf("user_email:%s", updatedUser.Email)
-	if err := us.cache.Delete(emailCacheKey); err != nil {
-		us.logger.Warn("Failed to invalidate user email cache", map[string]interface{}{
-			"error": err.Error(),
-			"email": updatedUser.Email,
-		})
+	var user *domain.User
+	var deletion *domain.UserDeletion
+	err = us.tx.WithinTxIsolation(ctx, ports.IsolationRepeatableRead, func(ctx context.Context) error {
+		user, err = us.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			us.logger.ErrorContext(ctx, "Failed to get user before deletion", map[string]interface{}{
+				"id":    id,
+				"error": err.Error(),
+			})
+			return err
+		}
+		if user.Status == domain.UserPendingDeletion {
+			return domain.ErrDeletionInProgress
+		}
+
+		deletion, err = us.deletions.StartDeletion(ctx, user)
+		if err != nil {
+			us.logger.ErrorContext(ctx, "Failed to start user deletion", map[string]interface{}{
+				"id":    id,
+				"error": err.Error(),
+			})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return updatedUser, nil
+	// The user is locked from now on, cached copies would still show it active
+	us.cache.invalidateUser(ctx, userID.String(),
+		fmt.Sprintf("user:%s", userID.String()),
+		fmt.Sprintf("user_email:%s", user.Email),
+	)
+	locked := *user
+	locked.Status = domain.UserPendingDeletion
+	us.cache.store(ctx, &locked)
+
+	// Only after commit, the bike service must not see a deletion that may roll back
+	us.deletions.Advance(ctx, deletion)
+	return deletion, nil
 }
 
-func (us *UserService) DeleteUser(ctx context.Context, id string) error {
+func (us *UserService) ChangeRole(ctx context.Context, id string, role domain.UserRole) (_ *domain.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.ChangeRole")
+	defer func() { endSpan(span, err) }()
+
 	userID, err := uuid.Parse(id)
 	if err != nil {
-		us.logger.Error("Invalid UUID format", map[string]interface{}{
-			"id":    id,
-			"error": err.Error(),
-		})
-		return fmt.Errorf("invalid ID format: %w", err)
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
 	}
 
-	user, err := us.repo.GetUserByID(ctx, userID)
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", domain.ErrValidation, role)
+	}
+
+	var before, updatedUser *domain.User
+	err = us.tx.WithinTxIsolation(ctx, ports.IsolationRepeatableRead, func(ctx context.Context) error {
+		before, err = us.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			us.logger.ErrorContext(ctx, "Failed to get user before role change", map[string]interface{}{
+				"id":    id,
+				"error": err.Error(),
+			})
+			return err
+		}
+		if before.Status == domain.UserPendingDeletion {
+			return domain.ErrDeletionInProgress
+		}
+
+		updatedUser, err = us.repo.UpdateUserRole(ctx, userID, role)
+		if err != nil {
+			us.logger.ErrorContext(ctx, "Failed to change user role", map[string]interface{}{
+				"id":    id,
+				"error": err.Error(),
+			})
+			return err
+		}
+
+		us.audit.Record(ctx, &domain.AuditEvent{
+			Action:   domain.AuditRoleChanged,
+			TargetID: &userID,
+			Changes:  userChanges(before, updatedUser),
+		})
+		return nil
+	})
 	if err != nil {
-		us.logger.Error("Failed to get user before deletion", map[string]interface{}{
-			"id":    id,
-			"error": err.Error(),
+		return nil, err
+	}
+
+	us.cache.invalidateUser(ctx, userID.String(),
+		fmt.Sprintf("user:%s", userID.String()),
+		fmt.Sprintf("user_email:%s", updatedUser.Email),
+	)
+	us.cache.store(ctx, updatedUser)
+
+	us.logger.InfoContext(ctx, "User role changed", map[string]interface{}{
+		"id":   id,
+		"from": string(before.Role),
+		"to":   string(role),
+	})
+	return updatedUser, nil
+}
+
+// ImportUsers creates all users or none, e.g. when accounts move from another system.
+// Passwords that are already bcrypt hashes are kept, the rest are hashed
+func (us *UserService) ImportUsers(ctx context.Context, users []domain.User) (err error) {
+	ctx, span := startSpan(ctx, "UserService.ImportUsers")
+	defer func() { endSpan(span, err) }()
+
+	if len(users) == 0 || len(users) > MaxImportUsers {
+		return fmt.Errorf("%w: between 1 and %d users can be imported at once", domain.ErrValidation, MaxImportUsers)
+	}
+	for i := range users {
+		if users[i].Role == "" {
+			users[i].Role = domain.AppUser
+		}
+		if !users[i].Role.Valid() {
+			return fmt.Errorf("%w: user %d: unknown role %q", domain.ErrValidation, i, users[i].Role)
+		}
+		if err := us.validateUser(&users[i]); err != nil {
+			return fmt.Errorf("user %d: %w", i, err)
+		}
+	}
+
+	if err := hashPasswords(ctx, users); err != nil {
+		us.logger.ErrorContext(ctx, "Error during hashing", map[string]interface{}{
+			"error":  err.Error(),
+			"method": "ImportUsers",
 		})
 		return err
 	}
 
-	if err := us.repo.DeleteUser(ctx, userID); err != nil {
-		us.logger.Error("Failed to delete user", map[string]interface{}{
-			"id":    id,
+	err = us.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := us.repo.ImportUsers(ctx, users); err != nil {
+			return err
+		}
+		us.audit.Record(ctx, &domain.AuditEvent{
+			Action: domain.AuditUsersImported,
+			Details: map[string]string{
+				"count": strconv.Itoa(len(users)),
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		us.logger.ErrorContext(ctx, "Failed to import users", map[string]interface{}{
 			"error": err.Error(),
+			"count": len(users),
 		})
 		return err
 	}
 
-	cacheKey := fmt.Sprintf("user:%s", id)
-	if err := us.cache.Delete(cacheKey); err != nil {
-		us.logger.Warn("Failed to invalidate user cache", map[string]interface{}{
-			"error": err.Error(),
-			"id":    id,
+	// The emails may be remembered as unknown from earlier login attempts
+	keys := make([]string, 0, len(users))
+	for _, user := range users {
+		keys = append(keys, fmt.Sprintf("user_email:%s", user.Email))
+	}
+	us.cache.invalidate(ctx, keys...)
+
+	us.logger.InfoContext(ctx, "Users imported", map[string]interface{}{
+		"count": len(users),
+	})
+	return nil
+}
+
+// hashPasswords hashes the plain passwords of users in parallel, bcrypt is slow on purpose
+func hashPasswords(ctx context.Context, users []domain.User) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.GOMAXPROCS(0))
+
+	for i := range users {
+		if _, err := bcrypt.Cost([]byte(users[i].Password)); err == nil {
+			continue
+		}
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			hashed, err := bcrypt.GenerateFromPassword([]byte(users[i].Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			users[i].Password = string(hashed)
+			return nil
 		})
 	}
+	return g.Wait()
+}
+
+func (us *UserService) SearchUsers(ctx context.Context, filter domain.UserSearchFilter) (_ []domain.UserSearchHit, _ int, err error) {
+	ctx, span := startSpan(ctx, "UserService.SearchUsers")
+	defer func() { endSpan(span, err) }()
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	if length := utf8.RuneCountInString(filter.Query); length < 2 || length > 100 {
+		return nil, 0, fmt.Errorf("%w: query must be 2 to 100 characters long", domain.ErrValidation)
+	}
+	filter.Variants = transliterations(filter.Query)
 
-	emailCacheKey := fmt.Sprintf("user_email:%s", user.Email)
-	if err := us.cache.Delete(emailCacheKey); err != nil {
-		us.logger.Warn("Failed to invalidate user email cache", map[string]interface{}{
+	hits, total, err := us.repo.SearchUsers(ctx, filter.Normalized())
+	if err != nil {
+		us.logger.ErrorContext(ctx, "Failed to search users", map[string]interface{}{
 			"error": err.Error(),
-			"email": user.Email,
 		})
+		return nil, 0, err
 	}
-
-	us.logger.Info("User deleted", map[string]interface{}{
-		"id": id,
-	})
-	return nil
+	return hits, total, nil
 }
 
 func (us *UserService) validateUser(user *domain.User) error {
 	if err := us.validate.Struct(user); err != nil {
-		return fmt.Errorf("validation failed: %s", err.Error())
+		return fmt.Errorf("%w: %s", domain.ErrValidation, err.Error())
 	}
 
 	date, err := time.Parse("2006-01-02", user.DateOfBirth)
 	if err != nil {
-		return fmt.Errorf("invalid date format")
+		return fmt.Errorf("%w: invalid date format", domain.ErrValidation)
 	}
 
 	age := time.Now().Year() - date.Year()
@@ -224,7 +529,7 @@ func (us *UserService) validateUser(user *domain.User) error {
 	}
 
 	if age < 6 {
-		return fmt.Errorf("user must be at least 6 years old")
+		return fmt.Errorf("%w: user must be at least 6 years old", domain.ErrValidation)
 	}
 
 	return nil
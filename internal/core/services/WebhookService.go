@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+const webhookSecretPrefix = "whsec_"
+
+type WebhookSettings struct {
+	// A delivery is dead-lettered after this many failed attempts
+	MaxAttempts int
+	// Delay before the second attempt, doubled for every next one
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	// Parallel deliveries per batch
+	Concurrency int
+	// Claimed deliveries are hidden from other replicas this long
+	Lease time.Duration
+}
+
+// WebhookService queues events for subscribed partners and delivers them.
+// It is an EventPublisher, so the outbox relay feeds it like any broker
+type WebhookService struct {
+	repo     ports.WebhookRepository
+	sender   ports.WebhookSender
+	logger   ports.LoggerPort
+	metrics  ports.MetricsPort
+	audit    ports.AuditPort
+	settings WebhookSettings
+}
+
+func NewWebhookService(
+	repo ports.WebhookRepository,
+	sender ports.WebhookSender,
+	logger ports.LoggerPort,
+	metrics ports.MetricsPort,
+	audit ports.AuditPort,
+	settings WebhookSettings,
+) *WebhookService {
+	return &WebhookService{
+		repo:     repo,
+		sender:   sender,
+		logger:   logger,
+		metrics:  metrics,
+		audit:    audit,
+		settings: settings,
+	}
+}
+
+func (s *WebhookService) CreateSubscription(ctx context.Context, rawURL string, events []domain.EventType) (_ *domain.WebhookSubscription, err error) {
+	ctx, span := startSpan(ctx, "WebhookService.CreateSubscription")
+	defer func() { endSpan(span, err) }()
+
+	if err := validateWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: at least one event is required", domain.ErrValidation)
+	}
+	for _, event := range events {
+		if !slices.Contains(domain.KnownEventTypes, event) {
+			return nil, fmt.Errorf("%w: unknown event %s", domain.ErrValidation, event)
+		}
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.repo.CreateSubscription(ctx, &domain.WebhookSubscription{
+		URL:    rawURL,
+		Events: events,
+		Secret: secret,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to create webhook", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditWebhookCreated,
+		TargetID: &subscription.ID,
+		Changes: map[string]domain.AuditChange{
+			"url":    {After: subscription.URL},
+			"events": {After: subscription.Events},
+			"secret": {Redacted: true},
+		},
+	})
+	return subscription, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) (_ []domain.WebhookSubscription, err error) {
+	ctx, span := startSpan(ctx, "WebhookService.ListSubscriptions")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.ListSubscriptions(ctx)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "WebhookService.DeleteSubscription")
+	defer func() { endSpan(span, err) }()
+
+	subscriptionID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
+	}
+
+	if err := s.repo.DeleteSubscription(ctx, subscriptionID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditWebhookDeleted,
+		TargetID: &subscriptionID,
+	})
+	return nil
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID string, filter domain.DeliveryFilter) (_ []domain.WebhookDelivery, _ int, err error) {
+	ctx, span := startSpan(ctx, "WebhookService.ListDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	id, err := uuid.Parse(subscriptionID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
+	}
+	switch filter.Status {
+	case "", domain.DeliveryPending, domain.DeliverySucceeded, domain.DeliveryDead:
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown status %s", domain.ErrValidation, filter.Status)
+	}
+
+	if _, err := s.repo.GetSubscription(ctx, id); err != nil {
+		return nil, 0, err
+	}
+
+	filter.SubscriptionID = id
+	return s.repo.ListDeliveries(ctx, filter.Normalized())
+}
+
+func (s *WebhookService) Redeliver(ctx context.Context, deliveryID string) (_ *domain.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "WebhookService.Redeliver")
+	defer func() { endSpan(span, err) }()
+
+	id, err := uuid.Parse(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidID, err)
+	}
+
+	delivery, err := s.repo.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last result stays visible until the next attempt replaces it
+	delivery.Status = domain.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.DeliveredAt = nil
+	if err := s.repo.SaveAttempt(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &domain.AuditEvent{
+		Action:   domain.AuditWebhookRedelivered,
+		TargetID: &delivery.SubscriptionID,
+		Details: map[string]string{
+			"delivery_id": delivery.ID.String(),
+			"event_id":    delivery.Event.ID.String(),
+		},
+	})
+	return delivery, nil
+}
+
+// Publish queues event for every subscription that wants it
+func (s *WebhookService) Publish(ctx context.Context, event domain.Event) error {
+	subscriptions, err := s.repo.ListSubscriptionsForEvent(ctx, event.Type)
+	if err != nil {
+		return fmt.Errorf("list webhooks for %s: %w", event.Type, err)
+	}
+
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	// Queued without the user event payload, it carries PII
+	payload, err := json.Marshal(domain.WebhookEventPayload{UserID: event.AggregateID})
+	if err != nil {
+		return fmt.Errorf("encode webhook payload for %s: %w", event.ID, err)
+	}
+	event.Payload = payload
+
+	deliveries := make([]domain.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, domain.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			Event:          event,
+		})
+	}
+	return s.repo.EnqueueDeliveries(ctx, deliveries)
+}
+
+// Run delivers due webhooks until ctx is cancelled
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.settings.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	deliveries, err := s.repo.ClaimDueDeliveries(ctx, s.settings.BatchSize, s.settings.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "Failed to claim webhook deliveries", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	group := errgroup.Group{}
+	group.SetLimit(max(s.settings.Concurrency, 1))
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		group.Go(func() error {
+			s.deliver(ctx, delivery)
+			return nil
+		})
+	}
+	_ = group.Wait()
+}
+
+func (s *WebhookService) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	// A subscription deleted meanwhile takes its deliveries with it
+	subscription, err := s.repo.GetSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrWebhookNotFound) && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "Failed to get webhook", map[string]interface{}{
+				"subscription_id": delivery.SubscriptionID.String(),
+				"error":           err.Error(),
+			})
+		}
+		return
+	}
+
+	statusCode, err := s.sender.Send(ctx, subscription, delivery)
+	if ctx.Err() != nil {
+		// Shutting down, the lease expires and another attempt follows
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	outcome := ports.OutcomeSuccess
+
+	switch {
+	case err == nil:
+		delivery.Status = domain.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= s.settings.MaxAttempts:
+		delivery.Status = domain.DeliveryDead
+		delivery.LastError = err.Error()
+		outcome = ports.OutcomeDead
+		s.logger.WarnContext(ctx, "Webhook delivery dead-lettered", map[string]interface{}{
+			"delivery_id":     delivery.ID.String(),
+			"subscription_id": subscription.ID.String(),
+			"attempts":        delivery.Attempts,
+			"error":           err.Error(),
+		})
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(s.backoff(delivery.Attempts))
+		outcome = ports.OutcomeRetry
+	}
+
+	s.metrics.IncrementCounter(ports.MetricWebhookDeliveries, map[string]string{
+		"outcome": outcome,
+	})
+
+	if err := s.repo.SaveAttempt(ctx, delivery); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to save webhook attempt", map[string]interface{}{
+			"delivery_id": delivery.ID.String(),
+			"error":       err.Error(),
+		})
+	}
+}
+
+// backoff is the delay after the given number of failed attempts
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.settings.BaseBackoff
+	for i := 1; i < attempts && delay < s.settings.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.settings.MaxBackoff)
+}
+
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", domain.ErrValidation)
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+var (
+	_ ports.WebhookService = (*WebhookService)(nil)
+	_ ports.EventPublisher = (*WebhookService)(nil)
+)
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// fakeWebhookRepo serves Publish only
+type fakeWebhookRepo struct {
+	ports.WebhookRepository
+	subscriptions []domain.WebhookSubscription
+	queued        []domain.WebhookDelivery
+}
+
+func (r *fakeWebhookRepo) ListSubscriptionsForEvent(ctx context.Context, eventType domain.EventType) ([]domain.WebhookSubscription, error) {
+	return r.subscriptions, nil
+}
+
+func (r *fakeWebhookRepo) EnqueueDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	r.queued = append(r.queued, deliveries...)
+	return nil
+}
+
+func TestWebhookService_PublishQueuesUserIDOnly(t *testing.T) {
+	repo := &fakeWebhookRepo{subscriptions: []domain.WebhookSubscription{{ID: uuid.New()}, {ID: uuid.New()}}}
+	service := NewWebhookService(repo, nil, testutil.NopLogger{}, testutil.NopMetrics{}, &fakeAudit{}, WebhookSettings{})
+
+	userID := uuid.New()
+	payload, _ := json.Marshal(domain.UserEventPayload{
+		ID:          userID,
+		Name:        "Иван Иванов",
+		Email:       "ivan@example.com",
+		DateOfBirth: "1990-01-01",
+	})
+	event := domain.Event{
+		ID:          uuid.New(),
+		Type:        domain.EventUserRegistered,
+		AggregateID: userID,
+		OccurredAt:  time.Now(),
+		Payload:     payload,
+	}
+
+	if err := service.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(repo.queued) != len(repo.subscriptions) {
+		t.Fatalf("queued %d deliveries, want %d", len(repo.queued), len(repo.subscriptions))
+	}
+
+	for _, delivery := range repo.queued {
+		if delivery.Event.ID != event.ID || delivery.Event.Type != event.Type || delivery.Event.AggregateID != userID {
+			t.Errorf("event = %+v, want the id, type and user of %+v", delivery.Event, event)
+		}
+
+		var data map[string]any
+		if err := json.Unmarshal(delivery.Event.Payload, &data); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		want := map[string]any{"user_id": userID.String()}
+		if len(data) != len(want) || data["user_id"] != want["user_id"] {
+			t.Errorf("payload = %s, want only the user id", delivery.Event.Payload)
+		}
+	}
+}
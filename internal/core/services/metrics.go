@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/domain"
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+// recordOutcome counts a business operation by the kind of error it ended with
+func recordOutcome(metrics ports.MetricsPort, name string, err error) {
+	metrics.IncrementCounter(name, map[string]string{
+		"outcome": outcomeOf(err),
+	})
+}
+
+func outcomeOf(err error) string {
+	switch {
+	case err == nil:
+		return ports.OutcomeSuccess
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return ports.OutcomeInvalidCredentials
+	case errors.Is(err, domain.ErrValidation), errors.Is(err, domain.ErrInvalidID):
+		return ports.OutcomeInvalid
+	case errors.Is(err, domain.ErrEmailAlreadyExists):
+		return ports.OutcomeConflict
+	case errors.Is(err, domain.ErrUserNotFound):
+		return ports.OutcomeNotFound
+	default:
+		return ports.OutcomeError
+	}
+}
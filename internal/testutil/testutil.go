@@ -0,0 +1,53 @@
+// Package testutil holds no-op adapters shared by tests
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/sm8ta/webike_user_microservice_nikita/internal/core/ports"
+)
+
+type NopLogger struct{}
+
+func (NopLogger) Info(msg string, fields map[string]interface{})                              {}
+func (NopLogger) Error(msg string, fields map[string]interface{})                             {}
+func (NopLogger) Debug(msg string, fields map[string]interface{})                             {}
+func (NopLogger) Warn(msg string, fields map[string]interface{})                              {}
+func (NopLogger) InfoContext(ctx context.Context, msg string, fields map[string]interface{})  {}
+func (NopLogger) ErrorContext(ctx context.Context, msg string, fields map[string]interface{}) {}
+func (NopLogger) DebugContext(ctx context.Context, msg string, fields map[string]interface{}) {}
+func (NopLogger) WarnContext(ctx context.Context, msg string, fields map[string]interface{})  {}
+func (NopLogger) InfoGRPC(ctx context.Context, msg string, fields any)                        {}
+func (NopLogger) ErrorGRPC(ctx context.Context, msg string, fields any)                       {}
+func (NopLogger) DebugGRPC(ctx context.Context, msg string, fields any)                       {}
+func (NopLogger) WarnGRPC(ctx context.Context, msg string, fields any)                        {}
+
+type NopMetrics struct{}
+
+func (NopMetrics) IncrementCounter(name string, labels map[string]string)                       {}
+func (NopMetrics) RecordDuration(name string, duration time.Duration, labels map[string]string) {}
+func (NopMetrics) ObserveValue(name string, value float64, labels map[string]string)            {}
+func (NopMetrics) SetGauge(name string, value float64, labels map[string]string)                {}
+func (NopMetrics) AddGauge(name string, delta float64, labels map[string]string)                {}
+
+// NopCache always misses
+type NopCache struct{}
+
+func (NopCache) Get(ctx context.Context, key string) ([]byte, error) { return nil, ports.ErrCacheMiss }
+func (NopCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return nil
+}
+func (NopCache) Delete(ctx context.Context, key string) error { return nil }
+func (NopCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+func (NopCache) MSet(ctx context.Context, items ...ports.CacheItem) error { return nil }
+func (NopCache) DeleteMany(ctx context.Context, keys ...string) error     { return nil }
+func (NopCache) InvalidateTags(ctx context.Context, tags ...string) error { return nil }
+
+var (
+	_ ports.LoggerPort  = NopLogger{}
+	_ ports.MetricsPort = NopMetrics{}
+	_ ports.CachePort   = NopCache{}
+)
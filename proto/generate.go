@@ -0,0 +1,4 @@
+// Package proto holds the gRPC API definitions and the code generated from them
+package proto
+
+//go:generate protoc -I . --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. user/v1/user.proto